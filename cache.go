@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// parseCache keeps the key/values read from each applications directory, keyed by the
+// directory's modification time. adding, removing, or renaming a file bumps that time, so an
+// unchanged directory can be served from the cache without opening any of its files. editing
+// a file in place doesn't, editors that save by renaming over the old file are fine
+type parseCache struct {
+	mu     sync.Mutex
+	prev   map[string]cachedDir
+	next   map[string]cachedDir
+	failed map[string]struct{}
+}
+
+type cachedDir struct {
+	modTime time.Time
+	files   []cachedFile
+}
+
+type cachedFile struct {
+	path      string
+	keyValues []keyValue
+}
+
+func loadParseCache(path string) (*parseCache, error) {
+	cache := &parseCache{
+		prev:   map[string]cachedDir{},
+		next:   map[string]cachedDir{},
+		failed: map[string]struct{}{},
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open cache file: %w", err)
+	}
+	defer f.Close()
+
+	var dirs []cacheDirGob
+	if err := gob.NewDecoder(f).Decode(&dirs); err != nil {
+		return nil, fmt.Errorf("decode cache file: %w", err)
+	}
+	for _, dir := range dirs {
+		cached := cachedDir{modTime: dir.ModTime}
+		for _, file := range dir.Files {
+			cachedFile := cachedFile{path: file.Path}
+			for _, kv := range file.KeyValues {
+				cachedFile.keyValues = append(cachedFile.keyValues, keyValue{
+					group:  kv.Group,
+					key:    kv.Key,
+					locale: kv.Locale,
+					value:  kv.Value,
+					line:   kv.Line,
+					spaced: kv.Spaced,
+				})
+			}
+			cached.files = append(cached.files, cachedFile)
+		}
+		cache.prev[dir.Path] = cached
+	}
+	return cache, nil
+}
+
+// lookup returns the cached files for dir if it hasn't changed since the cache was written.
+// otherwise dir is marked to be filled by store as its files are read
+func (c *parseCache) lookup(dir string) ([]cachedFile, bool) {
+	if c == nil {
+		return nil, false
+	}
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if prev, ok := c.prev[dir]; ok && prev.modTime.Equal(stat.ModTime()) {
+		c.next[dir] = prev
+		return prev.files, true
+	}
+	c.next[dir] = cachedDir{modTime: stat.ModTime()}
+	return nil, false
+}
+
+func (c *parseCache) store(dir, path string, keyValues []keyValue) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached := c.next[dir]
+	cached.files = append(cached.files, cachedFile{path: path, keyValues: keyValues})
+	c.next[dir] = cached
+}
+
+// fail keeps dir out of the saved cache, so that a file that couldn't be read is retried
+func (c *parseCache) fail(dir string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failed[dir] = struct{}{}
+}
+
+// save writes the directories seen in this run to path, replacing it atomically. they then
+// become the cache for the next run in the same process
+func (c *parseCache) save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prev, c.next = c.next, map[string]cachedDir{}
+	for dirPath := range c.failed {
+		delete(c.prev, dirPath)
+	}
+	clear(c.failed)
+
+	var dirs []cacheDirGob
+	for dirPath, dir := range c.prev {
+		dirGob := cacheDirGob{Path: dirPath, ModTime: dir.modTime}
+		for _, file := range dir.files {
+			fileGob := cacheFileGob{Path: file.path}
+			for _, kv := range file.keyValues {
+				fileGob.KeyValues = append(fileGob.KeyValues, cacheKeyValueGob{
+					Group:  kv.group,
+					Key:    kv.key,
+					Locale: kv.locale,
+					Value:  kv.value,
+					Line:   kv.line,
+					Spaced: kv.spaced,
+				})
+			}
+			dirGob.Files = append(dirGob.Files, fileGob)
+		}
+		dirs = append(dirs, dirGob)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := gob.NewEncoder(tmp).Encode(dirs); err != nil {
+		return fmt.Errorf("encode cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	return nil
+}
+
+// the gob types mirror the cache with exported fields
+type cacheDirGob struct {
+	Path    string
+	ModTime time.Time
+	Files   []cacheFileGob
+}
+
+type cacheFileGob struct {
+	Path      string
+	KeyValues []cacheKeyValueGob
+}
+
+type cacheKeyValueGob struct {
+	Group, Key, Locale, Value string
+	Line                      int
+	Spaced                    bool
+}
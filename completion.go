@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// completionScripts complete the names of the subcommands, and after launch the names of the
+// listed applications from -names-only. names can have spaces and quotes, so they're quoted
+// the way each shell wants for the command line
+var completionScripts = map[string]string{
+	"bash": `_xdg_desktop_list() {
+	local i cur=${COMP_WORDS[COMP_CWORD]} launch=0 name
+	COMPREPLY=()
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		launch) launch=1 ;;
+		-*) ;;
+		*) ((launch)) && return ;;
+		esac
+	done
+	if ((!launch)); then
+		COMPREPLY=($(compgen -W "launch mime verify-override command-histogram completion" -- "$cur"))
+		return
+	fi
+	while IFS= read -r name; do
+		[[ $name == "$cur"* ]] && COMPREPLY+=("$(printf '%q' "$name")")
+	done < <(xdg-desktop-list -names-only 2>/dev/null)
+}
+complete -F _xdg_desktop_list xdg-desktop-list
+`,
+	"zsh": `#compdef xdg-desktop-list
+_xdg_desktop_list() {
+	local -a names
+	if ((CURRENT == 2)); then
+		compadd launch mime verify-override command-histogram completion
+	elif [[ ${words[CURRENT-1]} == launch ]]; then
+		names=("${(@f)$(xdg-desktop-list -names-only 2>/dev/null)}")
+		compadd -a names
+	fi
+}
+compdef _xdg_desktop_list xdg-desktop-list
+`,
+	"fish": `complete -c xdg-desktop-list -n __fish_use_subcommand -f -a 'launch mime verify-override command-histogram completion'
+complete -c xdg-desktop-list -n '__fish_seen_subcommand_from launch' -f -a '(xdg-desktop-list -names-only 2>/dev/null)'
+`,
+}
+
+// completion prints the completion script for a shell, to be sourced by it
+func completion(args []string) error {
+	flags := flag.NewFlagSet("completion", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s completion bash|zsh|fish\n", os.Args[0])
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a shell")
+	}
+	script, ok := completionScripts[flags.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown shell %q", flags.Arg(0))
+	}
+	fmt.Fprint(os.Stdout, script)
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	dropinDirSuffix = ".d"
+	dropinSuffix    = ".conf"
+)
+
+// readDropins merges the fragments in the foo.desktop.d directory next to applicationFile over
+// keyValues, for -dropins. this isn't part of the spec, but follows systemd's drop-ins.
+// fragments are the *.conf files of the directory, merged in order of their numeric prefix,
+// so 9-a.conf before 10-b.conf, then by name. a fragment without a prefix sorts after those
+// with one. each key, per group and locale, takes the value from the last fragment that sets
+// it, keeping its place in the base file. keys the base doesn't have are added at the end
+func readDropins(applicationFile string, keyValues []keyValue) ([]keyValue, error) {
+	dir := applicationFile + dropinDirSuffix
+	ents, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return keyValues, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read drop-in dir: %w", err)
+	}
+
+	var fragments []string
+	for _, ent := range ents {
+		if !ent.IsDir() && strings.HasSuffix(ent.Name(), dropinSuffix) {
+			fragments = append(fragments, ent.Name())
+		}
+	}
+	slices.SortFunc(fragments, compareDropins)
+
+	type groupKey struct{ group, key, locale string }
+	merged := slices.Clone(keyValues)
+	index := map[groupKey]int{}
+	for i, kv := range merged {
+		index[groupKey{kv.group, kv.key, kv.locale}] = i
+	}
+	for _, fragment := range fragments {
+		fragmentKeyValues, err := readKeyValues(filepath.Join(dir, fragment))
+		if err != nil {
+			return nil, fmt.Errorf("drop-in %q: %w", fragment, err)
+		}
+		for _, kv := range fragmentKeyValues {
+			k := groupKey{kv.group, kv.key, kv.locale}
+			if i, ok := index[k]; ok {
+				merged[i] = kv
+				continue
+			}
+			index[k] = len(merged)
+			merged = append(merged, kv)
+		}
+	}
+	return merged, nil
+}
+
+// compareDropins orders fragment names by their numeric prefix, then by name
+func compareDropins(a, b string) int {
+	aNum, aOK := dropinPrefix(a)
+	bNum, bOK := dropinPrefix(b)
+	switch {
+	case aOK && !bOK:
+		return -1
+	case !aOK && bOK:
+		return 1
+	}
+	return cmp.Or(cmp.Compare(aNum, bNum), cmp.Compare(a, b))
+}
+
+func dropinPrefix(name string) (int, bool) {
+	end := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+	if end == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(name[:end])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
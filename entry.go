@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+const (
+	desktopEntryGroup        = "Desktop Entry"
+	desktopActionGroupPrefix = "Desktop Action "
+)
+
+// keyValue is a single entry from a desktop file, such as Name[de]=Firefox in the
+// [Desktop Entry] group
+type keyValue struct {
+	group  string
+	key    string
+	locale string
+	value  string
+	line   int
+
+	// spaced is whether there was whitespace around the =, as in Exec = firefox. the spec
+	// allows it, but Key=Value is canonical
+	spaced bool
+}
+
+// entryReader reads the key/value pairs of a desktop file in order, one per call to Next.
+// comments and blank lines are skipped, and lines that are neither a group header nor a
+// key/value pair are ignored. values are returned as written, without unescaping
+type entryReader struct {
+	scanner *bufio.Scanner
+	group   string
+	line    int
+	current keyValue
+}
+
+func newEntryReader(r io.Reader) *entryReader {
+	return &entryReader{scanner: bufio.NewScanner(r)}
+}
+
+// Next advances to the next key/value pair, returning false at the end of the input or on an
+// error
+func (r *entryReader) Next() bool {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			r.group = line[1 : len(line)-1]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		spaced := strings.TrimSpace(key) != key || strings.TrimSpace(value) != value
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var locale string
+		if i := strings.IndexByte(key, '['); i >= 0 && strings.HasSuffix(key, "]") {
+			key, locale = key[:i], key[i+1:len(key)-1]
+		}
+
+		r.current = keyValue{
+			group:  r.group,
+			key:    key,
+			locale: locale,
+			value:  value,
+			line:   r.line,
+			spaced: spaced,
+		}
+		return true
+	}
+	return false
+}
+
+// KeyValue returns the pair read by the last call to Next
+func (r *entryReader) KeyValue() keyValue {
+	return r.current
+}
+
+// Err returns the first error reading the input, if any
+func (r *entryReader) Err() error {
+	return r.scanner.Err()
+}
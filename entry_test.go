@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEntryReaderSpacing(t *testing.T) {
+	dir := filepath.Join("testdata", "spacing", "share", applicationsPath)
+	read := func(name string) []keyValue {
+		t.Helper()
+		f, err := os.Open(filepath.Join(dir, name+desktopSuffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		var keyValues []keyValue
+		reader := newEntryReader(f)
+		for reader.Next() {
+			keyValues = append(keyValues, reader.KeyValue())
+		}
+		if err := reader.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return keyValues
+	}
+
+	want := read("canonical")
+	for _, kv := range want {
+		if kv.spaced {
+			t.Errorf("canonical %s is spaced", kv.key)
+		}
+	}
+	for _, name := range []string{"before", "after", "both", "tabs", "wide"} {
+		got := read(name)
+		if len(got) != len(want) {
+			t.Fatalf("%s has %d keys, want %d", name, len(got), len(want))
+		}
+		for i, kv := range got {
+			if !kv.spaced {
+				t.Errorf("%s %s isn't spaced", name, kv.key)
+			}
+			kv.spaced = false
+			if kv != want[i] {
+				t.Errorf("%s line %d is %+v, want %+v", name, kv.line, kv, want[i])
+			}
+		}
+	}
+}
+
+func TestSpacingLint(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	})
+
+	applications := findTestdata(t, options{lint: true, locales: []string{"de"}}, "spacing/share")
+	if len(applications) != 6 {
+		t.Fatalf("got %d entries, want 6", len(applications))
+	}
+	for _, appl := range applications {
+		if appl.name != "Abstand" || appl.command != "spaced --flag " || !slices.Equal(appl.categories, []string{"Utility"}) {
+			t.Errorf("%s parsed as %q, %q, %q", appl.id, appl.name, appl.command, appl.categories)
+		}
+	}
+
+	lints := buf.String()
+	if strings.Contains(lints, "canonical") {
+		t.Errorf("canonical entry was linted:\n%s", lints)
+	}
+	for _, name := range []string{"before", "after", "both", "tabs", "wide"} {
+		for line, key := range []string{"Type", "Name", "Name[de]", "Exec", "Categories"} {
+			lint := fmt.Sprintf("lint %q: line %d: whitespace around = in %q", filepath.Join("testdata", "spacing", "share", applicationsPath, name+desktopSuffix), line+2, key)
+			if !strings.Contains(lints, lint) {
+				t.Errorf("missing %s", lint)
+			}
+		}
+	}
+}
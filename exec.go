@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// splitExec splits an Exec value into its arguments. arguments are separated by spaces and
+// may be double quoted, inside quotes a backslash escapes the next character. field codes are
+// returned as they are
+// https://specifications.freedesktop.org/desktop-entry-spec/latest/exec-variables.html
+func splitExec(value string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	var inArg, inQuotes bool
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case inQuotes && c == '\\':
+			if i+1 == len(value) {
+				return nil, errors.New("unterminated escape")
+			}
+			i++
+			arg.WriteByte(value[i])
+		case inQuotes && c == '"':
+			inQuotes = false
+		case inQuotes:
+			arg.WriteByte(c)
+		case c == '"':
+			inQuotes, inArg = true, true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inQuotes {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("no program")
+	}
+	return args, nil
+}
+
+// runnable reports whether the program an Exec value starts with can be found, either as a
+// path, relative ones being in workingDir, or by looking it up in $PATH
+func runnable(value, workingDir string) error {
+	args, err := splitExec(value)
+	if err != nil {
+		return fmt.Errorf("split exec: %w", err)
+	}
+	_, args = splitEnvAssignments(args)
+	if len(args) == 0 {
+		return errors.New("no program after environment assignments")
+	}
+	if _, err := exec.LookPath(resolveProgram(args[0], workingDir)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// splitEnvAssignments separates leading NAME=value arguments, as in
+// Exec=GDK_BACKEND=x11 myapp %U, from the program and its arguments. they set the environment
+// of the program the same way they would in a shell
+func splitEnvAssignments(args []string) (env, argv []string) {
+	for i, arg := range args {
+		name, _, ok := strings.Cut(arg, "=")
+		if !ok || !isEnvName(name) {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+// isEnvName reports whether name can be the name of an environment variable in a shell
+// assignment, made of [A-Za-z0-9_] and not starting with a digit
+func isEnvName(name string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveProgram returns the path of program when it's a relative path, like ./run.sh, and
+// the entry sets Path=. the program is run from there, so that's where it's found rather than
+// the current directory. names without a slash are left to $PATH
+func resolveProgram(program, workingDir string) string {
+	if workingDir == "" || filepath.IsAbs(program) || !strings.ContainsRune(program, filepath.Separator) {
+		return program
+	}
+	return filepath.Join(workingDir, program)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRunnableRelativeToPath(t *testing.T) {
+	tests := []struct {
+		id       string
+		runnable bool
+	}{
+		// ./run.sh is only in the Path= directory, not the one tests run in
+		{"inpath", true},
+		{"nopath", false},
+		{"notinpath", false},
+	}
+	applications := byID(findTestdata(t, options{}, "path/share"))
+	for _, tt := range tests {
+		appl, ok := applications[tt.id]
+		if !ok {
+			t.Fatalf("no entry for %q", tt.id)
+		}
+		if err := runnable(appl.exec, appl.workingDir); (err == nil) != tt.runnable {
+			t.Errorf("runnable(%q, %q) = %v, want runnable %t", appl.exec, appl.workingDir, err, tt.runnable)
+		}
+	}
+}
+
+func TestEnvAssignments(t *testing.T) {
+	tests := []struct {
+		id        string
+		env, argv []string
+	}{
+		{"multi", []string{"GDK_BACKEND=x11", "QT_QPA_PLATFORM=xcb", "LANG="}, []string{"myapp", "--name=x", "%U"}},
+		{"quoted", []string{"GREETING=hello world"}, []string{"myapp"}},
+		{"onlyenv", []string{"FOO=1", "BAR=2"}, nil},
+		// only leading assignments are the environment, others are arguments
+		{"later", nil, []string{"myapp", "A=b"}},
+		{"notname", nil, []string{"1FOO=x", "myapp"}},
+	}
+	applications := byID(findTestdata(t, options{}, "env/share"))
+	for _, tt := range tests {
+		appl, ok := applications[tt.id]
+		if !ok {
+			t.Fatalf("no entry for %q", tt.id)
+		}
+		if !slices.Equal(appl.env, tt.env) {
+			t.Errorf("%q env is %q, want %q", tt.id, appl.env, tt.env)
+		}
+		args, err := splitExec(appl.exec)
+		if err != nil {
+			t.Fatalf("split %q: %v", appl.exec, err)
+		}
+		if _, argv := splitEnvAssignments(args); !slices.Equal(argv, tt.argv) {
+			t.Errorf("%q argv is %q, want %q", tt.id, argv, tt.argv)
+		}
+	}
+
+	if err := runnable(applications["onlyenv"].exec, ""); err == nil {
+		t.Errorf("entry with only assignments is runnable")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+)
+
+// filterCmd passes applications through an external program for -filter-cmd. the program is
+// run once with sh -c, and gets each entry as a line of json on its stdin, the same as
+// -jsonl. for every line it must write a line back, in order: an empty line drops the entry,
+// otherwise the line is a json entry that replaces it. fields left out of the reply keep
+// their values, so a filter only needs to send what it changes. entries can be written before
+// replies are read, so a filter is free to read ahead. its stderr is passed through
+//
+// any failure fails the whole listing rather than letting entries through unfiltered. that's
+// the program exiting before replying to every entry, exiting non-zero, or replying with
+// something that isn't a json object
+func filterCmd(ctx context.Context, command string, applications []*application) ([]*application, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start: %w", err)
+	}
+
+	writeErr := make(chan error, 1)
+	go func() {
+		w := bufio.NewWriter(stdin)
+		enc := json.NewEncoder(w)
+		for _, appl := range applications {
+			if err := enc.Encode(appl); err != nil {
+				stdin.Close()
+				writeErr <- fmt.Errorf("encode %q: %w", appl.applicationFile, err)
+				return
+			}
+		}
+		if err := w.Flush(); err != nil {
+			stdin.Close()
+			writeErr <- err
+			return
+		}
+		writeErr <- stdin.Close()
+	}()
+
+	filtered, readErr := readFilterReplies(stdout, applications)
+	if readErr != nil {
+		cancel()
+	}
+	waitErr := cmd.Wait()
+	if readErr != nil {
+		return nil, readErr
+	}
+	if waitErr != nil {
+		return nil, fmt.Errorf("wait: %w", waitErr)
+	}
+	if err := <-writeErr; err != nil {
+		return nil, fmt.Errorf("write: %w", err)
+	}
+	return filtered, nil
+}
+
+// readFilterReplies reads a reply line from a filter for each of applications
+func readFilterReplies(stdout io.Reader, applications []*application) ([]*application, error) {
+	reader := bufio.NewScanner(stdout)
+	reader.Buffer(nil, 16<<20)
+
+	var filtered []*application
+	for i, appl := range applications {
+		if !reader.Scan() {
+			if err := reader.Err(); err != nil {
+				return nil, fmt.Errorf("read reply %d: %w", i+1, err)
+			}
+			return nil, fmt.Errorf("exited after replying to %d of %d entries", i, len(applications))
+		}
+		line := bytes.TrimSpace(reader.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		replaced, err := appl.withJSON(line)
+		if err != nil {
+			return nil, fmt.Errorf("reply %d for %q: %w", i+1, appl.applicationFile, err)
+		}
+		filtered = append(filtered, replaced)
+	}
+	return filtered, nil
+}
+
+// withJSON returns a copy of a with the fields set in data, a json entry. an entry whose
+// command changes gets it as its Exec too
+func (a *application) withJSON(data []byte) (*application, error) {
+	v := a.toJSON()
+	// the decoder appends into existing slices, which are shared with a
+	v.Env, v.Categories, v.Keywords = slices.Clone(v.Env), slices.Clone(v.Categories), slices.Clone(v.Keywords)
+	v.MimeTypes, v.Tags, v.ExcludedBy = slices.Clone(v.MimeTypes), slices.Clone(v.Tags), slices.Clone(v.ExcludedBy)
+	v.Actions = nil
+	// and can't allocate the embedded launch stats itself
+	if v.launchStats == nil {
+		v.launchStats = &launchStats{}
+	} else {
+		stats := *v.launchStats
+		v.launchStats = &stats
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if v.ID == "" {
+		return nil, errors.New("no id")
+	}
+	if a.launches == nil && *v.launchStats == (launchStats{}) {
+		v.launchStats = nil
+	}
+
+	replaced := copyApplication(a)
+	replaced.setJSON(v)
+	if replaced.command != a.command {
+		replaced.exec = replaced.command
+	}
+	return replaced, nil
+}
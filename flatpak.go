@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// flatpakAppDataDirs returns the data directories that hold the exported entries of the
+// flatpak app with id, in precedence order. those are the exports of the system and then the
+// user installation, then the app's own data directory under ~/.var/app, which it sees as
+// $XDG_DATA_HOME inside the sandbox
+// https://docs.flatpak.org/en/latest/conventions.html
+func flatpakAppDataDirs(id string) ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("find home dir: %w", err)
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	export := filepath.Join("app", id, "current", "active", "export", "share")
+	return []string{
+		filepath.Join("/var/lib/flatpak", export),
+		filepath.Join(dataHome, "flatpak", export),
+		filepath.Join(home, ".var", "app", id, "data"),
+	}, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// formatFuncs are the functions a -format template can call, beyond the text/template
+// builtins. lower lowercases a string, basename returns the last element of a path, quote
+// quotes a string for a POSIX shell, and join joins a list with a separator, as in
+// {{join .Categories ","}}
+var formatFuncs = template.FuncMap{
+	"lower":    strings.ToLower,
+	"basename": filepath.Base,
+	"quote":    shellQuote,
+	"join":     func(elems []string, sep string) string { return strings.Join(elems, sep) },
+}
+
+func parseFormat(text string) (*template.Template, error) {
+	return template.New("format").Funcs(formatFuncs).Parse(text)
+}
+
+// formatView is what a -format template sees for each entry
+type formatView struct {
+	ID         string
+	Action     string
+	Name       string
+	Icon       string
+	Category   string
+	Command    string
+	Exec       string
+	File       string
+	Categories []string
+	Keywords   []string
+	MimeTypes  []string
+	Score      float64
+}
+
+// writeFormat executes tmpl for each entry, ending each with recordSep
+func writeFormat(w io.Writer, applications []*application, tmpl *template.Template, recordSep string) error {
+	for _, appl := range applications {
+		view := formatView{
+			ID:         appl.id,
+			Action:     appl.action,
+			Name:       appl.name,
+			Icon:       appl.icon,
+			Category:   appl.category.String(),
+			Command:    appl.command,
+			Exec:       appl.exec,
+			File:       appl.applicationFile,
+			Categories: appl.categories,
+			Keywords:   appl.keywords,
+			MimeTypes:  appl.mimeTypes,
+			Score:      appl.score,
+		}
+		if err := tmpl.Execute(w, view); err != nil {
+			return fmt.Errorf("execute for %q: %w", appl.applicationFile, err)
+		}
+		if _, err := io.WriteString(w, recordSep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+)
+
+// commandHistogram prints how many of the listed entries run each program, most common first,
+// to help find entries that are wrappers around the same command
+func commandHistogram(ctx context.Context, xdgDataDirs []string, opts options, args []string) error {
+	flags := flag.NewFlagSet("command-histogram", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [flags] command-histogram\n", os.Args[0])
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return errors.New("expected no arguments")
+	}
+
+	applications, err := find(ctx, xdgDataDirs, 8, opts)
+	if err != nil {
+		return fmt.Errorf("find: %w", err)
+	}
+
+	counts := map[string]int{}
+	for _, appl := range applications {
+		execArgs, err := splitExec(appl.exec)
+		if err != nil {
+			log.Printf("skipping %q: split exec: %v", appl.applicationFile, err)
+			continue
+		}
+		if _, execArgs = splitEnvAssignments(execArgs); len(execArgs) == 0 {
+			log.Printf("skipping %q: no program after environment assignments", appl.applicationFile)
+			continue
+		}
+		counts[execArgs[0]]++
+	}
+
+	programs := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	for _, program := range programs {
+		fmt.Fprintf(os.Stdout, "%d\t%s\n", counts[program], program)
+	}
+	return nil
+}
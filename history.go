@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// launchHistory is how often and when applications were last launched, by id
+type launchHistory map[string]*launchStats
+
+// launchStats are the launch_count and last_launched fields. in json an entry without launches
+// has a count of 0 and null for the time
+type launchStats struct {
+	LaunchCount  int        `json:"launch_count"`
+	LastLaunched *time.Time `json:"last_launched"`
+}
+
+// readHistory reads a file of launches, one per line as a unix timestamp in seconds and an id
+// separated by whitespace. blank lines and lines starting with # are ignored
+func readHistory(path string) (launchHistory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open history file: %w", err)
+	}
+	defer f.Close()
+
+	history := launchHistory{}
+
+	reader := bufio.NewScanner(f)
+	for lineNum := 1; reader.Scan(); lineNum++ {
+		line := strings.TrimSpace(reader.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected timestamp and id", lineNum)
+		}
+		sec, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parse timestamp: %w", lineNum, err)
+		}
+		launched := time.Unix(sec, 0).UTC()
+
+		stats, ok := history[fields[1]]
+		if !ok {
+			stats = &launchStats{}
+			history[fields[1]] = stats
+		}
+		stats.LaunchCount++
+		if stats.LastLaunched == nil || launched.After(*stats.LastLaunched) {
+			stats.LastLaunched = &launched
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("read history file: %w", err)
+	}
+	return history, nil
+}
+
+// stats returns the launches of id, which are zero if it was never launched
+func (h launchHistory) stats(id string) *launchStats {
+	if stats, ok := h[id]; ok {
+		return stats
+	}
+	return &launchStats{}
+}
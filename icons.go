@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	iconThemeGroup   = "Icon Theme"
+	iconThemeIndex   = "index.theme"
+	iconThemeDefault = "hicolor"
+	pixmapsDir       = "/usr/share/pixmaps"
+)
+
+// iconExtensions are the image formats the icon theme spec allows, in order of preference
+var iconExtensions = []string{".png", ".svg", ".xpm"}
+
+// iconIndex finds the files for icon names, for -resolve-icons and -check-icons. the
+// directories of every theme are listed once when it's built, so that looking an icon up is
+// only map access
+// https://specifications.freedesktop.org/icon-theme-spec/latest/
+type iconIndex struct {
+	size int
+
+	// themes are the theme to look in and then the ones it inherits from, hicolor last
+	themes  []map[string][]iconFile
+	pixmaps map[string]string
+}
+
+// iconFile is an image for an icon name in one directory of a theme
+type iconFile struct {
+	dir  iconDir
+	path string
+}
+
+// iconDir is the size information of a directory from an index.theme
+type iconDir struct {
+	typ                               string
+	size, minSize, maxSize, threshold int
+}
+
+// newIconIndex lists theme and the themes it inherits from in each of baseDirs, highest
+// precedence first, for icons of size
+func newIconIndex(baseDirs []string, theme string, size int) *iconIndex {
+	index := &iconIndex{size: size, pixmaps: map[string]string{}}
+
+	seen := map[string]bool{}
+	queue := []string{theme}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		icons, inherits := listIconTheme(baseDirs, name)
+		if icons != nil {
+			index.themes = append(index.themes, icons)
+		}
+		queue = append(queue, inherits...)
+		if len(queue) == 0 && !seen[iconThemeDefault] {
+			queue = append(queue, iconThemeDefault)
+		}
+	}
+
+	if ents, err := os.ReadDir(pixmapsDir); err == nil {
+		for _, ent := range ents {
+			name, ok := cutIconExtension(ent.Name())
+			if !ok || ent.IsDir() {
+				continue
+			}
+			if _, ok := index.pixmaps[name]; !ok {
+				index.pixmaps[name] = filepath.Join(pixmapsDir, ent.Name())
+			}
+		}
+	}
+	return index
+}
+
+// listIconTheme returns the images of theme by icon name, from the first of baseDirs with an
+// index.theme for it, along with the themes it inherits from. the icons are nil if the theme
+// isn't installed
+func listIconTheme(baseDirs []string, theme string) (map[string][]iconFile, []string) {
+	var dirs map[string]iconDir
+	var dirNames, inherits []string
+	for _, baseDir := range baseDirs {
+		var err error
+		dirs, dirNames, inherits, err = readIconThemeIndex(filepath.Join(baseDir, theme, iconThemeIndex))
+		if err == nil {
+			break
+		}
+	}
+	if dirs == nil {
+		return nil, nil
+	}
+
+	icons := map[string][]iconFile{}
+	for _, baseDir := range baseDirs {
+		for _, dirName := range dirNames {
+			dir := filepath.Join(baseDir, theme, dirName)
+			ents, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, ent := range ents {
+				name, ok := cutIconExtension(ent.Name())
+				if !ok || ent.IsDir() {
+					continue
+				}
+				icons[name] = append(icons[name], iconFile{dir: dirs[dirName], path: filepath.Join(dir, ent.Name())})
+			}
+		}
+	}
+	return icons, inherits
+}
+
+// readIconThemeIndex reads the directories and parents of a theme from its index.theme.
+// directories for scales other than 1 are left out
+func readIconThemeIndex(path string) (map[string]iconDir, []string, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("open index: %w", err)
+	}
+	defer f.Close()
+
+	var dirNames, inherits []string
+	var attrs = map[string]map[string]string{}
+
+	reader := newEntryReader(f)
+	for reader.Next() {
+		kv := reader.KeyValue()
+		if kv.locale != "" {
+			continue
+		}
+		if kv.group == iconThemeGroup {
+			switch kv.key {
+			case "Directories":
+				dirNames = splitCommaList(kv.value)
+			case "Inherits":
+				inherits = splitCommaList(kv.value)
+			}
+			continue
+		}
+		if attrs[kv.group] == nil {
+			attrs[kv.group] = map[string]string{}
+		}
+		attrs[kv.group][kv.key] = kv.value
+	}
+	if err := reader.Err(); err != nil {
+		return nil, nil, nil, fmt.Errorf("read index: %w", err)
+	}
+
+	dirs := map[string]iconDir{}
+	dirNames = slices.DeleteFunc(dirNames, func(name string) bool {
+		dirAttrs := attrs[name]
+		if scale := dirAttrs["Scale"]; scale != "" && scale != "1" {
+			return true
+		}
+		size, err := strconv.Atoi(dirAttrs["Size"])
+		if err != nil {
+			return true
+		}
+		dir := iconDir{typ: "Threshold", size: size, minSize: size, maxSize: size, threshold: 2}
+		if typ := dirAttrs["Type"]; typ != "" {
+			dir.typ = typ
+		}
+		for key, v := range map[string]*int{"MinSize": &dir.minSize, "MaxSize": &dir.maxSize, "Threshold": &dir.threshold} {
+			if n, err := strconv.Atoi(dirAttrs[key]); err == nil {
+				*v = n
+			}
+		}
+		dirs[name] = dir
+		return false
+	})
+	return dirs, dirNames, inherits, nil
+}
+
+// lookup returns the file for the icon name, or name itself if it's already a path or can't
+// be found. each theme is tried in turn, taking a directory that matches the size or else the
+// closest one, and then the pixmaps directory. it reports whether the icon exists, which an
+// empty name always does
+func (x *iconIndex) lookup(name string) (string, bool) {
+	if name == "" {
+		return name, true
+	}
+	if filepath.IsAbs(name) {
+		_, err := os.Stat(name)
+		return name, err == nil
+	}
+	for _, icons := range x.themes {
+		files := icons[name]
+		if len(files) == 0 {
+			continue
+		}
+		best := slices.MinFunc(files, func(a, b iconFile) int {
+			return a.dir.sizeDistance(x.size) - b.dir.sizeDistance(x.size)
+		})
+		return best.path, true
+	}
+	if path, ok := x.pixmaps[name]; ok {
+		return path, true
+	}
+	return name, false
+}
+
+// sizeDistance is how far a directory is from holding icons of size, 0 when it matches. this
+// is DirectoryMatchesSize and DirectorySizeDistance from the spec, for a scale of 1
+func (d iconDir) sizeDistance(size int) int {
+	var lower, upper int
+	switch d.typ {
+	case "Fixed":
+		lower, upper = d.size, d.size
+	case "Scalable":
+		lower, upper = d.minSize, d.maxSize
+	default:
+		lower, upper = d.size-d.threshold, d.size+d.threshold
+	}
+	switch {
+	case size < lower:
+		return lower - size
+	case size > upper:
+		return size - upper
+	}
+	return 0
+}
+
+// iconBaseDirs are the directories icon themes are searched in, highest precedence first.
+// those are ~/.icons and then the icons directory of each data directory. later data
+// directories take precedence, as they do for entries
+func iconBaseDirs(xdgDataDirs []string) []string {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".icons"))
+	}
+	for _, dataDir := range slices.Backward(xdgDataDirs) {
+		dirs = append(dirs, filepath.Join(dataDir, "icons"))
+	}
+	return dirs
+}
+
+func cutIconExtension(file string) (string, bool) {
+	for _, ext := range iconExtensions {
+		if name, ok := strings.CutSuffix(file, ext); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// splitCommaList splits the comma separated lists of an index.theme
+func splitCommaList(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const largeIconTheme = "Large"
+
+var largeIconThemeSizes = []int{16, 22, 24, 32, 48, 64, 96, 128, 256}
+
+// writeLargeIconTheme makes a theme of n icons that inherits from hicolor, with each icon in
+// some of its sizes and a scalable directory, and every third one only in hicolor. it returns
+// the base directory and the icon names, along with some that don't exist
+func writeLargeIconTheme(tb testing.TB, n int) (string, []string) {
+	tb.Helper()
+	base := tb.TempDir()
+
+	writeTheme := func(theme string, inherits string, dirNames []string, index string) {
+		if err := os.MkdirAll(filepath.Join(base, theme), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		header := fmt.Sprintf("[Icon Theme]\nName=%s\nDirectories=%s\n", theme, strings.Join(dirNames, ","))
+		if inherits != "" {
+			header += "Inherits=" + inherits + "\n"
+		}
+		if err := os.WriteFile(filepath.Join(base, theme, iconThemeIndex), []byte(header+index), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	writeIcon := func(theme, dirName, file string) {
+		dir := filepath.Join(base, theme, dirName)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	var dirNames []string
+	var index strings.Builder
+	for _, size := range largeIconThemeSizes {
+		dirName := fmt.Sprintf("%dx%d/apps", size, size)
+		dirNames = append(dirNames, dirName)
+		fmt.Fprintf(&index, "\n[%s]\nSize=%d\n", dirName, size)
+	}
+	dirNames = append(dirNames, "scalable/apps")
+	index.WriteString("\n[scalable/apps]\nSize=128\nType=Scalable\nMinSize=8\nMaxSize=512\n")
+	writeTheme(largeIconTheme, iconThemeDefault, dirNames, index.String())
+	writeTheme(iconThemeDefault, "", []string{"48x48/apps"}, "\n[48x48/apps]\nSize=48\n")
+
+	var names []string
+	for i := range n {
+		name := fmt.Sprintf("org.example.App%d", i)
+		names = append(names, name)
+		if i%3 == 0 {
+			writeIcon(iconThemeDefault, "48x48/apps", name+".png")
+			continue
+		}
+		for j, dirName := range dirNames {
+			if (i+j)%4 == 0 {
+				ext := ".png"
+				if strings.HasPrefix(dirName, "scalable") {
+					ext = ".svg"
+				}
+				writeIcon(largeIconTheme, dirName, name+ext)
+			}
+		}
+	}
+	for i := range n / 10 {
+		names = append(names, fmt.Sprintf("org.example.Missing%d", i))
+	}
+	return base, names
+}
+
+// statIconLookup finds an icon the way lookup does, but by trying every directory and
+// extension of each theme with a stat, as a lookup without an index would
+func statIconLookup(baseDirs []string, themes []string, size int, name string) (string, bool) {
+	for _, theme := range themes {
+		var dirs map[string]iconDir
+		var dirNames []string
+		for _, baseDir := range baseDirs {
+			var err error
+			dirs, dirNames, _, err = readIconThemeIndex(filepath.Join(baseDir, theme, iconThemeIndex))
+			if err == nil {
+				break
+			}
+		}
+
+		var best string
+		bestDistance := -1
+		for _, baseDir := range baseDirs {
+			for _, dirName := range dirNames {
+				for _, ext := range iconExtensions {
+					path := filepath.Join(baseDir, theme, dirName, name+ext)
+					if _, err := os.Stat(path); err != nil {
+						continue
+					}
+					if distance := dirs[dirName].sizeDistance(size); bestDistance < 0 || distance < bestDistance {
+						best, bestDistance = path, distance
+					}
+				}
+			}
+		}
+		if best != "" {
+			return best, true
+		}
+	}
+	return name, false
+}
+
+func TestIconIndexMatchesStat(t *testing.T) {
+	base, names := writeLargeIconTheme(t, 300)
+	themes := []string{largeIconTheme, iconThemeDefault}
+	for _, size := range []int{16, 30, 48, 200, 512} {
+		index := newIconIndex([]string{base}, largeIconTheme, size)
+		for _, name := range names {
+			wantPath, wantOK := statIconLookup([]string{base}, themes, size, name)
+			if gotPath, gotOK := index.lookup(name); gotPath != wantPath || gotOK != wantOK {
+				t.Errorf("size %d, %q: got %q, %t, want %q, %t", size, name, gotPath, gotOK, wantPath, wantOK)
+			}
+		}
+	}
+}
+
+// BenchmarkIconIndex compares resolving every icon of a large theme through an index, built
+// each time as a run would, with a stat for every candidate file
+func BenchmarkIconIndex(b *testing.B) {
+	base, names := writeLargeIconTheme(b, 3000)
+
+	b.Run("index", func(b *testing.B) {
+		for range b.N {
+			index := newIconIndex([]string{base}, largeIconTheme, 48)
+			for _, name := range names {
+				index.lookup(name)
+			}
+		}
+	})
+	b.Run("stat", func(b *testing.B) {
+		themes := []string{largeIconTheme, iconThemeDefault}
+		for range b.N {
+			for _, name := range names {
+				statIconLookup([]string{base}, themes, 48, name)
+			}
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// index holds the results of the last find so that a long running process can answer lookups
+// from memory and refresh them with reload. it's safe for concurrent use, a reload swaps in
+// the new results at once so readers see either the old or the new ones. entries are returned
+// as copies, which callers are free to change
+type index struct {
+	xdgDataDirs []string
+	opts        *options
+
+	mu sync.RWMutex
+	// applications are the listed entries, names the listed ones by display name, and ids
+	// every winner of de-duplication, including those that are filtered out
+	applications []*application
+	ids          map[string]*application
+	names        map[string][]*application
+}
+
+// newIndex returns an empty index that finds entries in xdgDataDirs with opts, which are read
+// on every reload
+func newIndex(xdgDataDirs []string, opts *options) *index {
+	return &index{xdgDataDirs: xdgDataDirs, opts: opts}
+}
+
+// reload finds the entries again and replaces the previous results, which are kept if it fails
+func (x *index) reload(ctx context.Context) error {
+	applications, err := resolve(ctx, x.xdgDataDirs, 8, *x.opts)
+	if err != nil {
+		return err
+	}
+
+	ids := map[string]*application{}
+	for _, appl := range applications {
+		ids[strings.ToLower(appl.id)] = appl
+	}
+	applications = x.opts.filter(applications)
+	names := map[string][]*application{}
+	for _, appl := range applications {
+		names[appl.name] = append(names[appl.name], appl)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.applications, x.ids, x.names = applications, ids, names
+	return nil
+}
+
+// lookup returns the entry that won de-duplication for id, which like it ignores case. it may
+// be one that isn't listed, such as a Hidden entry
+func (x *index) lookup(id string) (*application, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	appl, ok := x.ids[strings.ToLower(id)]
+	if !ok {
+		return nil, false
+	}
+	return copyApplication(appl), true
+}
+
+// byName returns the listed entries with a display name, in listing order
+func (x *index) byName(name string) []*application {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	return copyApplications(x.names[name])
+}
+
+// all returns every entry in listing order
+func (x *index) all() []*application {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	return copyApplications(x.applications)
+}
+
+// copyApplication copies an entry so that changing its fields doesn't change the index. the
+// actions are clipped so that appending to them doesn't either
+func copyApplication(appl *application) *application {
+	c := *appl
+	c.actions = slices.Clip(c.actions)
+	return &c
+}
+
+func copyApplications(applications []*application) []*application {
+	copies := make([]*application, 0, len(applications))
+	for _, appl := range applications {
+		copies = append(copies, copyApplication(appl))
+	}
+	return copies
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// launchOptions control how an application is started
+type launchOptions struct {
+	// args are the files or URLs to open, passed through the Exec field codes
+	args []string
+	// terminal is the command that runs Terminal=true applications, with theirs appended
+	terminal []string
+	// dbus activates DBusActivatable applications over the session bus instead of running
+	// their Exec
+	dbus bool
+	// dryRun, if set, gets a description of what would be run instead of running it
+	dryRun io.Writer
+}
+
+// launchCommand starts the application for an id, as a launcher would after a selection
+func launchCommand(ctx context.Context, xdgDataDirs []string, opts options, args []string) error {
+	flags := flag.NewFlagSet("launch", flag.ExitOnError)
+	terminal := flags.String("terminal", defaultTerminal(), "command to run Terminal=true applications in, which must accept the program after it")
+	dbus := flags.Bool("dbus", true, "activate DBusActivatable applications over the session bus")
+	dryRun := flags.Bool("dry-run", false, "print how the application would be started, without starting it")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [flags] launch [-terminal CMD] [-dbus=false] [-dry-run] <id or name> [file or url...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return errors.New("expected an id")
+	}
+	id := strings.TrimSuffix(flags.Arg(0), desktopSuffix)
+
+	appl, err := lookupLaunchable(ctx, xdgDataDirs, opts, id)
+	if err != nil {
+		return err
+	}
+
+	terminalArgs, err := splitExec(*terminal)
+	if err != nil {
+		return fmt.Errorf("split terminal command: %w", err)
+	}
+
+	launchOpts := launchOptions{
+		args:     flags.Args()[1:],
+		terminal: terminalArgs,
+		dbus:     *dbus,
+	}
+	if *dryRun {
+		fmt.Fprintf(os.Stdout, "entry\t%s\n", appl.applicationFile)
+		launchOpts.dryRun = os.Stdout
+	}
+	return appl.launch(ctx, launchOpts)
+}
+
+// lookupLaunchable finds the winning entry for id. unlike listing, NoDisplay and Terminal
+// entries can be launched, they are only hidden from menus. Hidden entries count as deleted.
+// if no id matches, id is taken as the name of a listed entry, such as one completed from
+// -names-only, as long as only one has it
+func lookupLaunchable(ctx context.Context, xdgDataDirs []string, opts options, id string) (*application, error) {
+	applicationIndex := newIndex(xdgDataDirs, &opts)
+	if err := applicationIndex.reload(ctx); err != nil {
+		return nil, fmt.Errorf("resolve: %w", err)
+	}
+	if appl, ok := applicationIndex.lookup(id); ok && appl.launchable() {
+		return appl, nil
+	}
+
+	named := applicationIndex.byName(id)
+	switch len(named) {
+	case 0:
+		return nil, fmt.Errorf("no entry for id %q", id)
+	case 1:
+		return named[0], nil
+	}
+	return nil, fmt.Errorf("no entry for id %q, and %d are named it", id, len(named))
+}
+
+// launchable reports whether an entry can be started, whether or not it's listed. Hidden
+// entries count as deleted, and ones that aren't applications or have nothing to run are only
+// kept with -no-default-filters
+func (a *application) launchable() bool {
+	return !slices.ContainsFunc(a.excludedBy, func(reason string) bool {
+		return reason == skipHidden || reason == skipNotApplication || reason == skipNoExec
+	})
+}
+
+// defaultTerminal is $TERMINAL with -e, or xterm
+func defaultTerminal() string {
+	if terminal := os.Getenv("TERMINAL"); terminal != "" {
+		return terminal + " -e"
+	}
+	return "xterm -e"
+}
+
+// launch starts the application without waiting for it to exit. a DBusActivatable
+// application is activated over the session bus when opts allow, otherwise its Exec is
+// expanded with the field codes, wrapped in a terminal if it asks for one, and run from its
+// Path= if set, with any leading environment assignments in its environment. %f and %u only
+// take a single argument, so with several Exec is run once for each of them. with
+// opts.dryRun each step is described as a line starting with "dbus" or "exec" instead, with
+// the directory to run in or "-" for exec
+func (a *application) launch(ctx context.Context, opts launchOptions) error {
+	if a.hints.DBusActivatable && opts.dbus {
+		argv := a.activateArgs(opts.args)
+		if opts.dryRun != nil {
+			fmt.Fprintf(opts.dryRun, "dbus\t%s\n", shellJoin(argv))
+			return nil
+		}
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("activate %q: %w: %s", a.id, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	if a.exec == "" {
+		return fmt.Errorf("%q has no Exec", a.applicationFile)
+	}
+
+	commands, err := a.expandExec(opts.args)
+	if err != nil {
+		return fmt.Errorf("expand exec: %w", err)
+	}
+
+	for _, argv := range commands {
+		env, argv := splitEnvAssignments(argv)
+		if len(argv) == 0 {
+			return fmt.Errorf("%q has no program after environment assignments", a.applicationFile)
+		}
+		argv[0] = resolveProgram(argv[0], a.workingDir)
+		if a.terminal {
+			argv = append(append([]string(nil), opts.terminal...), argv...)
+		}
+		if opts.dryRun != nil {
+			dir := a.workingDir
+			if dir == "" {
+				dir = "-"
+			}
+			fmt.Fprintf(opts.dryRun, "exec\t%s\t%s\n", dir, shellJoin(slices.Concat(env, argv)))
+			continue
+		}
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Dir = a.workingDir
+		if env != nil {
+			cmd.Env = append(os.Environ(), env...)
+		}
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("start %q: %w", argv[0], err)
+		}
+		if err := cmd.Process.Release(); err != nil {
+			return fmt.Errorf("release %q: %w", argv[0], err)
+		}
+	}
+	return nil
+}
+
+// activateArgs is the gdbus command line that calls Activate, or Open with the arguments as
+// URIs, on the application's org.freedesktop.Application interface
+// https://specifications.freedesktop.org/desktop-entry-spec/latest/dbus.html
+func (a *application) activateArgs(args []string) []string {
+	objectPath := "/" + strings.NewReplacer(".", "/", "-", "_").Replace(a.id)
+
+	callArgs := []string{"gdbus", "call", "--session", "--dest", a.id, "--object-path", objectPath}
+	if len(args) == 0 {
+		callArgs = append(callArgs, "--method", "org.freedesktop.Application.Activate", "{}")
+	} else {
+		var uris []string
+		for _, arg := range args {
+			uris = append(uris, fmt.Sprintf("'%s'", strings.ReplaceAll(toURI(arg), "'", `\'`)))
+		}
+		callArgs = append(callArgs, "--method", "org.freedesktop.Application.Open", "["+strings.Join(uris, ", ")+"]", "{}")
+	}
+	return callArgs
+}
+
+// expandExec returns the command lines to run for an Exec value and arguments. %f and %F are
+// given local paths, %u and %U URLs or paths as they were given, %i the icon, %c the name,
+// %k the path of the desktop file, and %% a literal %. deprecated codes are dropped
+// https://specifications.freedesktop.org/desktop-entry-spec/latest/exec-variables.html
+func (a *application) expandExec(args []string) ([][]string, error) {
+	fields, err := splitExec(a.exec)
+	if err != nil {
+		return nil, err
+	}
+
+	// a single file or url code means one instance per argument
+	var single bool
+	for _, field := range fields {
+		if field == "%f" || field == "%u" {
+			single = true
+		}
+	}
+	if !single || len(args) <= 1 {
+		return [][]string{a.expandFields(fields, args)}, nil
+	}
+	var commands [][]string
+	for _, arg := range args {
+		commands = append(commands, a.expandFields(fields, []string{arg}))
+	}
+	return commands, nil
+}
+
+func (a *application) expandFields(fields []string, args []string) []string {
+	var argv []string
+	for _, field := range fields {
+		switch field {
+		case "%f":
+			if len(args) > 0 {
+				argv = append(argv, toPath(args[0]))
+			}
+			continue
+		case "%F":
+			for _, arg := range args {
+				argv = append(argv, toPath(arg))
+			}
+			continue
+		case "%u":
+			if len(args) > 0 {
+				argv = append(argv, args[0])
+			}
+			continue
+		case "%U":
+			argv = append(argv, args...)
+			continue
+		case "%i":
+			if a.icon != "" {
+				argv = append(argv, "--icon", a.icon)
+			}
+			continue
+		}
+
+		var b strings.Builder
+		for i := 0; i < len(field); i++ {
+			if field[i] != '%' || i+1 == len(field) {
+				b.WriteByte(field[i])
+				continue
+			}
+			i++
+			switch field[i] {
+			case '%':
+				b.WriteByte('%')
+			case 'c':
+				b.WriteString(a.name)
+			case 'k':
+				b.WriteString(a.applicationFile)
+			}
+		}
+		argv = append(argv, b.String())
+	}
+	return argv
+}
+
+// toPath turns a file URL into a local path, leaving anything else alone
+func toPath(arg string) string {
+	if u, err := url.Parse(arg); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return arg
+}
+
+// toURI turns a local path into a file URL, leaving URLs alone
+func toURI(arg string) string {
+	if u, err := url.Parse(arg); err == nil && u.Scheme != "" {
+		return arg
+	}
+	if abs, err := filepath.Abs(arg); err == nil {
+		arg = abs
+	}
+	return (&url.URL{Scheme: "file", Path: arg}).String()
+}
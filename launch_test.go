@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExpandExecDesktopFile(t *testing.T) {
+	appl, ok := byID(findTestdata(t, options{}, "fieldcodes"))["self"]
+	if !ok {
+		t.Fatal("no entry for self")
+	}
+	path := filepath.Join("testdata", "fieldcodes", applicationsPath, "self.desktop")
+	if appl.applicationFile != path {
+		t.Fatalf("file is %q, want %q", appl.applicationFile, path)
+	}
+
+	commands, err := appl.expandExec([]string{"/tmp/a", "/tmp/b"})
+	if err != nil {
+		t.Fatalf("expand exec: %v", err)
+	}
+	want := [][]string{{"cat", path, "--from=" + path, "%k"}}
+	if !slices.EqualFunc(commands, want, slices.Equal) {
+		t.Errorf("got %q, want %q", commands, want)
+	}
+}
+
+func TestLaunchDBusWithoutExec(t *testing.T) {
+	ctx := context.Background()
+	appl, err := lookupLaunchable(ctx, []string{filepath.Join("testdata", "dbus", "share")}, options{}, "org.example.App")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "dbus\tgdbus call --session --dest org.example.App --object-path /org/example/App --method org.freedesktop.Application.Activate '{}'\n"},
+		{[]string{"file:///tmp/a.txt"}, "dbus\tgdbus call --session --dest org.example.App --object-path /org/example/App --method org.freedesktop.Application.Open '['\\''file:///tmp/a.txt'\\'']' '{}'\n"},
+	}
+	for _, tt := range tests {
+		var dryRun bytes.Buffer
+		if err := appl.launch(ctx, launchOptions{args: tt.args, dbus: true, dryRun: &dryRun}); err != nil {
+			t.Fatalf("launch %q: %v", tt.args, err)
+		}
+		if got := dryRun.String(); got != tt.want {
+			t.Errorf("launch %q\n got %s\nwant %s", tt.args, got, tt.want)
+		}
+	}
+
+	// without D-Bus there's nothing to run
+	if err := appl.launch(ctx, launchOptions{dbus: false, dryRun: &bytes.Buffer{}}); err == nil {
+		t.Error("launch without D-Bus succeeded")
+	}
+}
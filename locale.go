@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// messagesLocale is the locale used for messages, from the first of $LC_ALL, $LC_MESSAGES,
+// and $LANG that is set
+func messagesLocale() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// localeCandidates returns the locale keys to look for, best match first, for a locale like
+// lang_COUNTRY.ENCODING@MODIFIER. the encoding is never part of a key and is dropped, then
+// lang_COUNTRY@MODIFIER, lang_COUNTRY, lang@MODIFIER, and lang are tried in that order,
+// leaving out the forms that need a part the locale doesn't have. C and POSIX, with any
+// encoding or modifier like C.UTF-8, have no translations
+// https://specifications.freedesktop.org/desktop-entry-spec/latest/localized-keys.html
+func localeCandidates(locale string) []string {
+	rest, modifier, hasModifier := strings.Cut(locale, "@")
+	rest, _, _ = strings.Cut(rest, ".")
+	if rest == "" || rest == "C" || rest == "POSIX" {
+		return nil
+	}
+	lang, country, hasCountry := strings.Cut(rest, "_")
+
+	var candidates []string
+	if hasCountry && hasModifier {
+		candidates = append(candidates, lang+"_"+country+"@"+modifier)
+	}
+	if hasCountry {
+		candidates = append(candidates, lang+"_"+country)
+	}
+	if hasModifier {
+		candidates = append(candidates, lang+"@"+modifier)
+	}
+	return append(candidates, lang)
+}
+
+type localizedKey struct {
+	group, key, locale string
+}
+
+// localized picks the best translation of a key for locales from values, or false if there
+// isn't one
+func localized(values map[localizedKey]string, locales []string, group, key string) (string, bool) {
+	for _, locale := range locales {
+		if value, ok := values[localizedKey{group, key, locale}]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
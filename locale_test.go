@@ -0,0 +1,67 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLocaleCandidates(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"", nil},
+		{"C", nil},
+		{"POSIX", nil},
+		{"C.UTF-8", nil},
+		{"C.utf8", nil},
+		{"POSIX@x", nil},
+		{".UTF-8", nil},
+		{"sr", []string{"sr"}},
+		{"sr_RS", []string{"sr_RS", "sr"}},
+		{"sr_RS.UTF-8", []string{"sr_RS", "sr"}},
+		{"sr@latin", []string{"sr@latin", "sr"}},
+		{"sr.UTF-8@latin", []string{"sr@latin", "sr"}},
+		{"sr_RS@latin", []string{"sr_RS@latin", "sr_RS", "sr@latin", "sr"}},
+		{"sr_RS.UTF-8@latin", []string{"sr_RS@latin", "sr_RS", "sr@latin", "sr"}},
+	}
+	for _, tt := range tests {
+		if got := localeCandidates(tt.locale); !slices.Equal(got, tt.want) {
+			t.Errorf("localeCandidates(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestLocalized(t *testing.T) {
+	locales := localeCandidates("sr_RS.UTF-8@latin")
+	all := map[string]string{
+		"sr_RS@latin": "Kalkulator (RS, latinica)",
+		"sr_RS":       "Калкулатор (RS)",
+		"sr@latin":    "Kalkulator",
+		"sr":          "Калкулатор",
+		"de":          "Rechner",
+	}
+
+	// each level is the best match once the ones before it are missing
+	for i, level := range locales {
+		values := map[localizedKey]string{}
+		for _, locale := range slices.Concat(locales[i:], []string{"de"}) {
+			values[localizedKey{desktopEntryGroup, "Name", locale}] = all[locale]
+		}
+		got, ok := localized(values, locales, desktopEntryGroup, "Name")
+		if !ok || got != all[level] {
+			t.Errorf("with %q and below, got %q, %t, want %q", level, got, ok, all[level])
+		}
+	}
+
+	values := map[localizedKey]string{
+		{desktopEntryGroup, "Name", "de"}:    all["de"],
+		{desktopEntryGroup, "Comment", "sr"}: all["sr"],
+	}
+	if got, ok := localized(values, locales, desktopEntryGroup, "Name"); ok {
+		t.Errorf("with only other locales, got %q, want none", got)
+	}
+	if got, ok := localized(values, locales, "Desktop Action new", "Comment"); ok {
+		t.Errorf("with only another group, got %q, want none", got)
+	}
+}
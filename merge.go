@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// policies for ids in both the local listing and one merged in with -merge-from
+const (
+	mergeKeepLocal  = "local"
+	mergeKeepMerged = "merged"
+	mergeKeepBoth   = "both"
+)
+
+// readListing reads the entries of a -jsonl listing, such as one captured on another host,
+// labelling each with source. fields that json output doesn't have, like Path=, are left
+// empty, and Exec= is taken from the command
+func readListing(path, source string) ([]*application, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open listing: %w", err)
+	}
+	defer f.Close()
+
+	var applications []*application
+
+	dec := json.NewDecoder(f)
+	for {
+		// the decoder can't allocate the embedded launch stats itself
+		v := applicationJSON{launchStats: &launchStats{}}
+		if err := dec.Decode(&v); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decode entry %d: %w", len(applications)+1, err)
+		}
+		if *v.launchStats == (launchStats{}) {
+			v.launchStats = nil
+		}
+
+		appl := &application{}
+		appl.setJSON(v)
+		appl.exec = v.Command
+		appl.source = source
+		applications = append(applications, appl)
+	}
+	return applications, nil
+}
+
+// setJSON sets the fields of a that json output has from v, other than the nested actions
+func (a *application) setJSON(v applicationJSON) {
+	var categ category
+	for _, name := range strings.Fields(v.Category) {
+		switch name {
+		case "user":
+			categ |= categoryUser
+		case "flatpak":
+			categ |= categoryFlatpak
+		}
+	}
+
+	a.dirIndex = v.DirIndex
+	a.applicationFile = v.File
+	a.dir = v.Dir
+	a.category = categ
+	a.source = v.Source
+	a.id = v.ID
+	a.action = v.Action
+	a.name = v.Name
+	a.icon = v.Icon
+	a.command = v.Command
+	a.env = v.Env
+	a.execFallback = v.ExecFallback
+	a.categories = v.Categories
+	a.keywords = v.Keywords
+	a.mimeTypes = v.MimeTypes
+	a.hints = v.Hints
+	a.score = v.Score
+	a.shadowedCount = v.ShadowedCount
+	a.tags = v.Tags
+	a.slug = v.Slug
+	a.launches = v.launchStats
+	a.excludedBy = v.ExcludedBy
+}
+
+// mergeListings adds the merged entries after the local ones. ids in both are resolved by
+// policy, keeping the local or the merged entry, or both of them. like de-duplication, ids
+// that only differ in case are the same
+func mergeListings(local, merged []*application, policy string) []*application {
+	localIDs := map[string]bool{}
+	for _, appl := range local {
+		localIDs[strings.ToLower(appl.id)] = true
+	}
+	mergedIDs := map[string]bool{}
+	for _, appl := range merged {
+		mergedIDs[strings.ToLower(appl.id)] = true
+	}
+
+	switch policy {
+	case mergeKeepLocal:
+		merged = slices.DeleteFunc(slices.Clone(merged), func(appl *application) bool { return localIDs[strings.ToLower(appl.id)] })
+	case mergeKeepMerged:
+		local = slices.DeleteFunc(slices.Clone(local), func(appl *application) bool { return mergedIDs[strings.ToLower(appl.id)] })
+	}
+	return slices.Concat(local, merged)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeListingsIgnoresCase(t *testing.T) {
+	local := []*application{{id: "firefox"}, {id: "local-only"}}
+	merged := []*application{{id: "Firefox", source: "host"}, {id: "merged-only", source: "host"}}
+
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{mergeKeepLocal, []string{"firefox", "local-only", "merged-only"}},
+		{mergeKeepMerged, []string{"local-only", "Firefox", "merged-only"}},
+		{mergeKeepBoth, []string{"firefox", "local-only", "Firefox", "merged-only"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, appl := range mergeListings(local, merged, tt.policy) {
+			got = append(got, appl.id)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s kept %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
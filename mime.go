@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	mimeappsListName         = "mimeapps.list"
+	addedAssociationsGroup   = "Added Associations"
+	removedAssociationsGroup = "Removed Associations"
+)
+
+// mimeQuery prints the ids of the applications that handle a mime type, most preferred first
+func mimeQuery(ctx context.Context, xdgDataDirs []string, opts options, args []string) error {
+	flags := flag.NewFlagSet("mime", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [flags] mime <type>\n", os.Args[0])
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one mime type")
+	}
+	mimeType := flags.Arg(0)
+
+	applications, err := mimeHandlers(ctx, xdgDataDirs, opts, mimeType)
+	if err != nil {
+		return err
+	}
+	for _, appl := range applications {
+		fmt.Fprintln(os.Stdout, appl.id)
+	}
+	return nil
+}
+
+// mimeHandlers returns the applications that handle mimeType, most preferred first. there are
+// no visibility rules for associations, so entries that menus leave out, like Terminal=true or
+// NoDisplay=true ones, can handle types too. only those that can't be launched are left out
+func mimeHandlers(ctx context.Context, xdgDataDirs []string, opts options, mimeType string) ([]*application, error) {
+	applications, err := resolve(ctx, xdgDataDirs, 8, opts)
+	if err != nil {
+		return nil, fmt.Errorf("resolve: %w", err)
+	}
+	applications = slices.DeleteFunc(applications, func(appl *application) bool {
+		return !appl.launchable()
+	})
+
+	lists, err := readMimeappsLists(mimeappsListPaths(xdgDataDirs))
+	if err != nil {
+		return nil, fmt.Errorf("read mimeapps.list: %w", err)
+	}
+	return resolveMimeType(mimeType, applications, lists), nil
+}
+
+// mimeappsList is the association groups of one mimeapps.list, mime type to application ids
+type mimeappsList struct {
+	added   map[string][]string
+	removed map[string][]string
+}
+
+// resolveMimeType returns the applications associated with mimeType. lists runs from the
+// highest precedence file to the lowest. each file's added associations count unless a higher
+// file removed them, and its removals apply to every lower file. after all of the lists, the
+// MimeType= of installed applications is considered, minus anything removed by any list. so a
+// removal always wins over an application that claims the type itself
+// https://specifications.freedesktop.org/mime-apps-spec/latest/ar01s03.html
+func resolveMimeType(mimeType string, applications []*application, lists []mimeappsList) []*application {
+	byID := map[string]*application{}
+	for _, appl := range applications {
+		byID[appl.id] = appl
+	}
+
+	var results []*application
+	removed := map[string]struct{}{}
+	add := func(id string) {
+		appl, ok := byID[id]
+		if !ok {
+			return
+		}
+		if _, ok := removed[id]; ok {
+			return
+		}
+		if slices.Contains(results, appl) {
+			return
+		}
+		results = append(results, appl)
+	}
+
+	for _, list := range lists {
+		for _, id := range list.added[mimeType] {
+			add(id)
+		}
+		for _, id := range list.removed[mimeType] {
+			removed[id] = struct{}{}
+		}
+	}
+	for _, appl := range applications {
+		if slices.Contains(appl.mimeTypes, mimeType) {
+			add(appl.id)
+		}
+	}
+	return results
+}
+
+// mimeappsListPaths returns the mimeapps.list files to consider, highest precedence first. the
+// user's config comes first, then $XDG_CONFIG_DIRS, then the applications directory of each
+// data directory, latest first as with desktop entries
+func mimeappsListPaths(xdgDataDirs []string) []string {
+	var paths []string
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if configHome != "" {
+		paths = append(paths, filepath.Join(configHome, mimeappsListName))
+	}
+
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+	for _, dir := range splitDataDirs(configDirs) {
+		paths = append(paths, filepath.Join(dir, mimeappsListName))
+	}
+
+	for _, dir := range slices.Backward(xdgDataDirs) {
+		paths = append(paths, filepath.Join(dir, applicationsPath, mimeappsListName))
+	}
+	return paths
+}
+
+// readMimeappsLists reads each of paths that exists
+func readMimeappsLists(paths []string) ([]mimeappsList, error) {
+	var lists []mimeappsList
+	for _, path := range paths {
+		keyValues, err := readKeyValues(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		list := mimeappsList{
+			added:   map[string][]string{},
+			removed: map[string][]string{},
+		}
+		for _, kv := range keyValues {
+			var ids []string
+			for _, id := range splitList(kv.value) {
+				ids = append(ids, strings.TrimSuffix(id, desktopSuffix))
+			}
+			switch kv.group {
+			case addedAssociationsGroup:
+				list.added[kv.key] = append(list.added[kv.key], ids...)
+			case removedAssociationsGroup:
+				list.removed[kv.key] = append(list.removed[kv.key], ids...)
+			}
+		}
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestResolveMimeTypeRemovals(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join("testdata", "mime", "config"))
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join("testdata", "mime", "missing"))
+
+	tests := []struct {
+		mimeType string
+		want     []string
+	}{
+		// the user's removal wins over both the system's added association and the viewer's
+		// own MimeType=
+		{"text/plain", []string{"editor", "other"}},
+		// a lower file's removal doesn't undo a higher one's added association, but still
+		// takes away editor's MimeType=
+		{"image/png", []string{"viewer", "other"}},
+		// the default is a Terminal=true and NoDisplay=true entry, which menus leave out but
+		// still handles the type. the Hidden=true one named before it counts as deleted
+		{"text/x-csrc", []string{"vim", "menu"}},
+		{"text/html", nil},
+	}
+	for _, tt := range tests {
+		applications, err := mimeHandlers(context.Background(), []string{filepath.Join("testdata", "mime", "share")}, options{}, tt.mimeType)
+		if err != nil {
+			t.Fatalf("mime handlers: %v", err)
+		}
+		var got []string
+		for _, appl := range applications {
+			got = append(got, appl.id)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s resolved to %q, want %q", tt.mimeType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+)
+
+// report counts what happened to the files of each directory for -report. when it's nil
+// nothing is counted
+type report struct {
+	mu   sync.Mutex
+	dirs []reportCounts
+}
+
+type reportCounts struct {
+	Dir     string         `json:"dir,omitempty"`
+	Files   int            `json:"files"`
+	Kept    int            `json:"kept"`
+	Skipped map[string]int `json:"skipped"`
+	Errors  int            `json:"errors"`
+}
+
+func newReport(xdgDataDirs []string) *report {
+	r := &report{}
+	for _, dataDir := range xdgDataDirs {
+		r.dirs = append(r.dirs, reportCounts{
+			Dir:     filepath.Join(dataDir, applicationsPath),
+			Skipped: map[string]int{},
+		})
+	}
+	return r
+}
+
+func (r *report) count(dirIndex int, f func(*reportCounts)) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f(&r.dirs[dirIndex])
+}
+
+func (r *report) file(dirIndex int) { r.count(dirIndex, func(c *reportCounts) { c.Files++ }) }
+func (r *report) keep(dirIndex int) { r.count(dirIndex, func(c *reportCounts) { c.Kept++ }) }
+func (r *report) fail(dirIndex int) { r.count(dirIndex, func(c *reportCounts) { c.Errors++ }) }
+
+func (r *report) skip(dirIndex int, reason string) {
+	r.count(dirIndex, func(c *reportCounts) { c.Skipped[reason]++ })
+}
+
+// write prints the counts for every directory, in precedence order, along with their totals
+func (r *report) write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := reportCounts{Skipped: map[string]int{}}
+	for _, c := range r.dirs {
+		total.Files += c.Files
+		total.Kept += c.Kept
+		total.Errors += c.Errors
+		for reason, n := range c.Skipped {
+			total.Skipped[reason] += n
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(struct {
+		Dirs  []reportCounts `json:"dirs"`
+		Total reportCounts   `json:"total"`
+	}{r.dirs, total}); err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	return nil
+}
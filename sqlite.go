@@ -0,0 +1,182 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema holds every field of the json output. lists get a table each, ordered by
+// position, and last_launched is null for entries that were never launched
+const sqliteSchema = `
+create table applications (
+	id                      text primary key,
+	action                  text not null,
+	name                    text not null,
+	generic_name            text not null,
+	comment                 text not null,
+	icon                    text not null,
+	category                text not null,
+	source                  text not null,
+	command                 text not null,
+	exec                    text not null,
+	working_dir             text not null,
+	exec_fallback           integer not null,
+	file                    text not null,
+	dir_index               integer not null,
+	dir                     text not null,
+	score                   real not null,
+	shadowed_count          integer not null,
+	slug                    text not null,
+	launch_count            integer not null,
+	last_launched           text,
+	uses_notifications      integer not null,
+	single_main_window      integer not null,
+	dbus_activatable        integer not null,
+	prefers_non_default_gpu integer not null
+);
+create table categories (
+	application_id text not null references applications (id),
+	position       integer not null,
+	category       text not null,
+	primary key (application_id, position)
+);
+create table keywords (
+	application_id text not null references applications (id),
+	position       integer not null,
+	keyword        text not null,
+	primary key (application_id, position)
+);
+create table env (
+	application_id text not null references applications (id),
+	position       integer not null,
+	assignment     text not null,
+	primary key (application_id, position)
+);
+create table tags (
+	application_id text not null references applications (id),
+	position       integer not null,
+	tag            text not null,
+	primary key (application_id, position)
+);
+create table excluded_by (
+	application_id text not null references applications (id),
+	position       integer not null,
+	reason         text not null,
+	primary key (application_id, position)
+);
+create table mime_types (
+	application_id text not null references applications (id),
+	position       integer not null,
+	mime_type      text not null,
+	primary key (application_id, position)
+);
+create table actions (
+	application_id text not null references applications (id),
+	position       integer not null,
+	action_id      text not null,
+	name           text not null,
+	icon           text not null,
+	exec           text not null,
+	primary key (application_id, position)
+);
+create index categories_category on categories (category);
+create index keywords_keyword on keywords (keyword);
+create index tags_tag on tags (tag);
+create index mime_types_mime_type on mime_types (mime_type);
+`
+
+// writeSQLite replaces the database at path with one holding applications. it's built in a
+// temporary file next to path and renamed over it, so that readers never see a partial one
+func writeSQLite(path string, applications []*application) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	db, err := sql.Open("sqlite", tmp.Name())
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := insertApplications(db, applications); err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("close database: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	return nil
+}
+
+func insertApplications(db *sql.DB, applications []*application) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("create schema: %w", err)
+	}
+
+	for _, appl := range applications {
+		var launchCount int
+		var lastLaunched *string
+		if appl.launches != nil {
+			launchCount = appl.launches.LaunchCount
+			if appl.launches.LastLaunched != nil {
+				last := appl.launches.LastLaunched.Format(time.RFC3339)
+				lastLaunched = &last
+			}
+		}
+		if _, err := tx.Exec(
+			`insert into applications values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			appl.id, appl.action, appl.name, appl.genericName, appl.comment, appl.icon,
+			appl.category.String(), appl.source, appl.command, appl.exec, appl.workingDir,
+			appl.execFallback, appl.applicationFile, appl.dirIndex, appl.dir, appl.score,
+			appl.shadowedCount, appl.slug, launchCount, lastLaunched,
+			appl.hints.UsesNotifications, appl.hints.SingleMainWindow, appl.hints.DBusActivatable,
+			appl.hints.PrefersNonDefaultGPU,
+		); err != nil {
+			return fmt.Errorf("insert %q: %w", appl.applicationFile, err)
+		}
+		lists := []struct {
+			table  string
+			values []string
+		}{
+			{"categories", appl.categories},
+			{"keywords", appl.keywords},
+			{"env", appl.env},
+			{"tags", appl.tags},
+			{"excluded_by", appl.excludedBy},
+			{"mime_types", appl.mimeTypes},
+		}
+		for _, list := range lists {
+			for i, value := range list.values {
+				if _, err := tx.Exec(`insert into `+list.table+` values (?, ?, ?)`, appl.id, i, value); err != nil {
+					return fmt.Errorf("insert %s of %q: %w", list.table, appl.applicationFile, err)
+				}
+			}
+		}
+		for i, act := range appl.actions {
+			if _, err := tx.Exec(`insert into actions values (?, ?, ?, ?, ?, ?)`, appl.id, i, act.id, act.name, act.icon, act.exec); err != nil {
+				return fmt.Errorf("insert actions of %q: %w", appl.applicationFile, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
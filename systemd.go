@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// systemdScopeCommand wraps command so that it runs in a transient user scope named after the
+// application, which desktops use to track an application's processes and resources. the
+// command is split as an Exec value and quoted again for a POSIX shell. leading environment
+// assignments become --setenv options, since systemd-run would otherwise take the first of
+// them as the program
+// https://systemd.io/DESKTOP_ENVIRONMENTS/
+func systemdScopeCommand(id, command string) (string, error) {
+	args, err := splitExec(command)
+	if err != nil {
+		return "", fmt.Errorf("split exec: %w", err)
+	}
+	env, argv := splitEnvAssignments(args)
+	if len(argv) == 0 {
+		return "", errors.New("no program after environment assignments")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "systemd-run --user --scope --unit=%s", shellQuote("app-"+escapeUnitName(id)))
+	for _, assignment := range env {
+		fmt.Fprintf(&b, " --setenv=%s", shellQuote(assignment))
+	}
+	fmt.Fprintf(&b, " -- %s", shellJoin(argv))
+	return b.String(), nil
+}
+
+// escapeUnitName escapes s like systemd-escape does. ASCII letters, digits, ":", "_", and "."
+// are kept, except for a leading ".", and everything else becomes a \xNN byte escape. dashes
+// are escaped too since systemd reads them as separators in unit names
+func escapeUnitName(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i == 0:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == ':', c == '_', c == '.':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestSystemdScopeCommand(t *testing.T) {
+	tests := []struct {
+		id, command string
+		want        string
+	}{
+		{"org.gnome.Calculator", "gnome-calculator", "systemd-run --user --scope --unit=app-org.gnome.Calculator -- gnome-calculator"},
+		{"foo-bar", "foo", `systemd-run --user --scope --unit='app-foo\x2dbar' -- foo`},
+		{"multi", `GDK_BACKEND=x11 "QT_QPA_PLATFORM=xcb" LANG= myapp --name=x`,
+			"systemd-run --user --scope --unit=app-multi --setenv=GDK_BACKEND=x11 --setenv=QT_QPA_PLATFORM=xcb --setenv=LANG= -- myapp --name=x"},
+		{"quoted", `"GREETING=hello world" myapp`, "systemd-run --user --scope --unit=app-quoted --setenv='GREETING=hello world' -- myapp"},
+		// the Exec quoting is undone and the arguments quoted for a shell the same way,
+		// whether or not there are assignments
+		{"sh", "sh -c \"echo \\$HOME \\`id\\`\"", "systemd-run --user --scope --unit=app-sh -- sh -c 'echo $HOME `id`'"},
+		{"sh", "A=1 sh -c \"echo \\$HOME \\`id\\`\"", "systemd-run --user --scope --unit=app-sh --setenv=A=1 -- sh -c 'echo $HOME `id`'"},
+	}
+	for _, tt := range tests {
+		got, err := systemdScopeCommand(tt.id, tt.command)
+		if err != nil {
+			t.Errorf("systemdScopeCommand(%q, %q): %v", tt.id, tt.command, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("systemdScopeCommand(%q, %q)\n got %s\nwant %s", tt.id, tt.command, got, tt.want)
+		}
+	}
+
+	for _, command := range []string{`foo "bar`, "FOO=1 BAR=2"} {
+		if got, err := systemdScopeCommand("foo", command); err == nil {
+			t.Errorf("systemdScopeCommand(%q) = %q, want an error", command, got)
+		}
+	}
+}
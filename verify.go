@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// verifyOverride reports which file won de-duplication for an id, failing if it didn't come
+// from the expected data directory. hidden entries still count, hiding an application is a
+// common reason to override it
+func verifyOverride(ctx context.Context, xdgDataDirs []string, opts options, args []string) error {
+	flags := flag.NewFlagSet("verify-override", flag.ExitOnError)
+	expectDir := flags.String("expect-dir", "", "data directory (or its applications directory) that should provide the winning entry")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [flags] verify-override [-expect-dir DIR] <id>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one id")
+	}
+	id := strings.TrimSuffix(flags.Arg(0), desktopSuffix)
+
+	applicationIndex := newIndex(xdgDataDirs, &opts)
+	if err := applicationIndex.reload(ctx); err != nil {
+		return fmt.Errorf("resolve: %w", err)
+	}
+	winner, ok := applicationIndex.lookup(id)
+	if !ok {
+		return fmt.Errorf("no entry for id %q", id)
+	}
+
+	fmt.Fprintf(os.Stdout, "%s\t%d\n", winner.applicationFile, winner.dirIndex)
+
+	if *expectDir == "" {
+		return nil
+	}
+	want := filepath.Clean(*expectDir)
+	dataDir := filepath.Clean(xdgDataDirs[winner.dirIndex])
+	if want != dataDir && want != filepath.Join(dataDir, applicationsPath) {
+		return fmt.Errorf("winner for %q is from %q, expected %q", id, dataDir, want)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	watchInterval = time.Second
+	watchDebounce = 500 * time.Millisecond
+)
+
+// watch lists once, then again whenever the applications directories change, until ctx is
+// done. directories are polled rather than watched with inotify to stay portable, and a burst
+// of changes such as a package upgrade is waited out before listing. each listing goes to
+// output if set, or stdout, unless onChange is set, in which case that shell command is run
+// with the listing on its stdin. with both, the command runs after output has been written
+func watch(ctx context.Context, xdgDataDirs []string, list func(io.Writer) error, onChange string, output string) error {
+	regenerate := func() {
+		if err := runOnChange(ctx, list, onChange, output); err != nil {
+			log.Printf("error regenerating listing: %v", err)
+		}
+	}
+
+	prev := watchSnapshot(xdgDataDirs)
+	regenerate()
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		curr := watchSnapshot(xdgDataDirs)
+		if curr == prev {
+			continue
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(watchDebounce):
+			}
+			next := watchSnapshot(xdgDataDirs)
+			if next == curr {
+				break
+			}
+			curr = next
+		}
+		prev = curr
+		regenerate()
+	}
+}
+
+func runOnChange(ctx context.Context, list func(io.Writer) error, onChange string, output string) error {
+	var stdin io.Reader
+	switch {
+	case output != "":
+		if err := writeOutput(output, list); err != nil {
+			return err
+		}
+	case onChange != "":
+		var buf bytes.Buffer
+		if err := list(&buf); err != nil {
+			return err
+		}
+		stdin = &buf
+	default:
+		return list(os.Stdout)
+	}
+
+	if onChange == "" {
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", onChange)
+	cmd.Stdin = stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("run %q: %w", onChange, err)
+	}
+	return nil
+}
+
+// watchSnapshot fingerprints the names, sizes, and modification times of everything in the
+// applications directories, which changes with any edit, addition, or removal
+func watchSnapshot(xdgDataDirs []string) string {
+	h := sha256.New()
+	for _, dataDir := range xdgDataDirs {
+		applicationDir := filepath.Join(dataDir, applicationsPath)
+		dirEnt, err := os.ReadDir(applicationDir)
+		if err != nil {
+			fmt.Fprintf(h, "%s\x00missing\n", applicationDir)
+			continue
+		}
+		for _, ent := range dirEnt {
+			info, err := ent.Info()
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00%d\n", filepath.Join(applicationDir, ent.Name()), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return string(h.Sum(nil))
+}
@@ -2,14 +2,27 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -19,43 +32,1263 @@ const (
 )
 
 func main() {
+	rofi := flag.Bool("rofi", false, "print entries for rofi -dmenu, with the id in each row's info field")
+	fuzzel := flag.Bool("fuzzel", false, "print entries for fuzzel --dmenu, with icon names")
+	wofi := flag.Bool("wofi", false, "print entries for wofi --dmenu --allow-images, with icons that are paths")
+	hash := flag.Bool("hash", false, "print a sha-256 of the result set instead of the entries")
+	lint := flag.Bool("lint", false, "warn about problems found in desktop entries")
+	verbose := flag.Bool("v", false, "log extra detail about how entries are resolved")
+	jsonl := flag.Bool("jsonl", false, "print entries as newline delimited json")
+	stream := flag.Bool("stream", false, "with -jsonl, print entries as they are found, without sorting or de-duplication")
+	scoresPath := flag.String("scores", "", "read entry scores from a file of \"id score\" lines")
+	sortBy := flag.String("sort", sortDir, fmt.Sprintf("sort entries by %q precedence or %q", sortDir, sortScore))
+	fieldSepFlag := flag.String("field-sep", `\t`, "separator between fields of the text output, escapes such as \\t \\n \\0 are allowed")
+	recordSepFlag := flag.String("record-sep", `\n`, "separator between entries of the text output, escapes such as \\t \\n \\0 are allowed")
+	var excludeCategories stringsFlag
+	flag.Var(&excludeCategories, "exclude-category", "hide entries listing this value in Categories=, may be repeated")
+	var prependDataDirs stringsFlag
+	flag.Var(&prependDataDirs, "prepend-data-dir", "scan this data directory with precedence over $XDG_DATA_DIRS, may be repeated")
+	var flatpakApps stringsFlag
+	flag.Var(&flatpakApps, "flatpak-app", "also scan the exported entries of the flatpak app with this id, over $XDG_DATA_DIRS but under -prepend-data-dir, may be repeated")
+	cachePath := flag.String("since-cache", "", "reuse entries from this cache file for directories that haven't changed since, and update it")
+	strict := flag.Bool("strict", false, "exit non-zero, printing every problem, if any file couldn't be read or failed validation")
+	validateID := flag.Bool("validate-desktop-file-id", false, "warn about desktop file ids that aren't reverse-DNS names")
+	actionExecFallback := flag.Bool("action-exec-fallback", false, "use the Exec of the first action for entries without one, marked with exec_fallback in json")
+	systemdScope := flag.Bool("systemd-scope", false, "wrap commands to run in their own systemd user scope, named from the id")
+	outputPath := flag.String("output", "", "write the listing to this file, replacing it atomically, instead of stdout")
+	watchDirs := flag.Bool("watch", false, "keep running, and list again whenever the applications directories change")
+	onChange := flag.String("on-change", "", "with -watch, run this shell command on every change, with the listing on stdin or after writing -output")
+	onlyRunnable := flag.Bool("only-runnable", false, "only list entries whose program exists, as a path or in $PATH")
+	stats := flag.Bool("stats", false, "print counts about the listing to stderr")
+	tiebreakCategory := flag.String("tiebreak-category", "", "between entries for an id at the same precedence, prefer the one with this category, or without it as \"!flatpak\". by default the later directory wins, and then the lowest sorting id")
+	keyword := flag.String("keyword", "", "only list entries with this word in Keywords= or Categories=")
+	showShadowCount := flag.Bool("show-shadow-count", false, "add a column with the number of lower precedence files each entry overrides")
+	withActions := flag.Bool("actions", false, "also list each entry's desktop actions as entries of their own")
+	collapseActions := flag.Bool("collapse-actions", false, "with -actions, nest actions under their application's \"actions\" in json output instead of listing them")
+	shellAliases := flag.Bool("shell-aliases", false, "print an alias definition for each entry, for sourcing in a POSIX shell")
+	ignoreShowIn := flag.Bool("ignore-showin", false, "list entries regardless of OnlyShowIn= and NotShowIn= for $XDG_CURRENT_DESKTOP")
+	reportFlag := flag.Bool("report", false, "print json counts of the files in each directory and why any were skipped, instead of the entries")
+	noDefaultFilters := flag.Bool("no-default-filters", false, "keep entries that are normally left out, such as hidden or terminal ones, with the reasons in excluded_by in json output")
+	sqlitePath := flag.String("sqlite", "", "write the entries to this sqlite database, replacing it atomically, instead of printing them")
+	resolveIcons := flag.Bool("resolve-icons", false, "replace icon names with the path of the best matching file from the icon theme")
+	checkIcons := flag.Bool("check-icons", false, "warn about entries and actions whose Icon= isn't in the icon theme or /usr/share/pixmaps at any size")
+	iconTheme := flag.String("icon-theme", iconThemeDefault, "with -resolve-icons or -check-icons, the icon theme to look in before the ones it inherits from")
+	iconSize := flag.Int("icon-size", 48, "with -resolve-icons or -check-icons, the size in pixels to pick icons for")
+	formatText := flag.String("format", "", "print each entry with this go text/template, given its ID, Action, Name, Icon, Category, Command, Exec, File, Categories, Keywords, MimeTypes, and Score, and the functions lower, basename, quote, and join, ending entries with -record-sep")
+	mergeTags := flag.Bool("merge-tags", false, "add a \"tags\" field to json output, combining Categories=, Keywords=, and the words of GenericName= in lower case without duplicates")
+	mergeNames := flag.Bool("merge-duplicate-names-into-actions", false, "list entries that share a name as actions of the first of them, named by their ids. needs -actions")
+	jsonMapBy := flag.String("json-map-by", "", fmt.Sprintf("print a json object of the entries keyed by %q, or by %q with arrays as values since names can collide", jsonMapByID, jsonMapByName))
+	baselinePath := flag.String("baseline", "", "only list entries whose id isn't in this file of ids, one per line, and exit non-zero if there are any")
+	includeTerminal := flag.String("include-terminal", "", "list Terminal=true entries whose category is made up only of these comma separated names, such as \"system,user\"")
+	showPath := flag.Bool("show-path", false, "add a column with the path of each entry's file")
+	pathRoot := flag.String("path-root", "", "print the paths of files under this directory relative to it, in -show-path and json output")
+	dropins := flag.Bool("dropins", false, "merge the keys of foo.desktop.d/*.conf over each foo.desktop, in order of their numeric prefix")
+	historyPath := flag.String("history", "", "add launch_count and last_launched to json output from a file of \"unix-time id\" lines, one per launch")
+	gnomeSearch := flag.Bool("gnome-search", false, "print a json array of result metas shaped like a GNOME Shell search provider's GetResultMetas")
+	mergeFrom := flag.String("merge-from", "", "add the entries of this -jsonl listing, such as one from another host, after the local ones")
+	mergeLabel := flag.String("merge-label", "", "with -merge-from, the \"source\" to label the merged entries with in json output, by default the listing's path")
+	mergeDedup := flag.String("merge-dedup", mergeKeepLocal, fmt.Sprintf("with -merge-from, for ids in both listings keep the %q entry, the %q one, or %q", mergeKeepLocal, mergeKeepMerged, mergeKeepBoth))
+	sanitize := flag.Bool("sanitize", false, "strip control characters, such as escape sequences, tabs and newlines, from every field before output")
+	namesOnly := flag.Bool("names-only", false, "print only the name of each entry, one per line, as used by the completion scripts")
+	showDir := flag.Bool("show-dir", false, "add a column with the applications directory each entry comes from")
+	maxNameLength := flag.Int("max-name-length", 0, "shorten names longer than this many characters, ending them with an ellipsis")
+	withSlug := flag.Bool("with-slug", false, "add a url safe \"slug\" made from the name to json output, with a numeric suffix for names that collide")
+	rawStream := flag.Bool("raw-stream", false, "print every parsed entry as newline delimited json as it's found, without filtering, sorting, or de-duplication, for resolving precedence with dir_index yourself")
+	filterCommand := flag.String("filter-cmd", "", "pass the entries as json lines through this shell command, which replies to each with an empty line to drop it or a json entry to replace it")
+	flag.Parse()
+
+	if *sortBy != sortDir && *sortBy != sortScore {
+		fmt.Fprintf(os.Stderr, "unknown sort %q\n", *sortBy)
+		os.Exit(1)
+	}
+	if *jsonMapBy != "" && *jsonMapBy != jsonMapByID && *jsonMapBy != jsonMapByName {
+		fmt.Fprintf(os.Stderr, "unknown json map key %q\n", *jsonMapBy)
+		os.Exit(1)
+	}
+
+	fieldSep, recordSep := unescapeSeparator(*fieldSepFlag), unescapeSeparator(*recordSepFlag)
+	if fieldSep == "" || recordSep == "" {
+		fmt.Fprintf(os.Stderr, "separators can't be empty\n")
+		os.Exit(1)
+	}
+	if strings.Contains(fieldSep, recordSep) || strings.Contains(recordSep, fieldSep) {
+		fmt.Fprintf(os.Stderr, "field separator %q and record separator %q collide\n", fieldSep, recordSep)
+		os.Exit(1)
+	}
+
+	var format *template.Template
+	if *formatText != "" {
+		var err error
+		format, err = parseFormat(*formatText)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "parse format: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	xdgDataDirsEnv, ok := os.LookupEnv(xdgDataDirsEnvKey)
 	if !ok {
 		fmt.Fprintf(os.Stderr, "$%s not set\n", xdgDataDirsEnvKey)
 		os.Exit(1)
 	}
 
-	xdgDataDirs := strings.Split(xdgDataDirsEnv, string(os.PathListSeparator))
+	xdgDataDirs := splitDataDirs(xdgDataDirsEnv)
+	var dirLevels []int
+	for i := range xdgDataDirs {
+		dirLevels = append(dirLevels, i)
+	}
+
+	// the directories of a flatpak app are for the same app, from a system or a user install
+	// or its own data, so they share a level and -tiebreak-category can pick between them
+	for _, id := range flatpakApps {
+		dirs, err := flatpakAppDataDirs(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "flatpak app %q: %v\n", id, err)
+			os.Exit(1)
+		}
+		level := len(xdgDataDirs)
+		for _, dir := range dirs {
+			xdgDataDirs = append(xdgDataDirs, dir)
+			dirLevels = append(dirLevels, level)
+		}
+	}
+
+	// later directories win de-duplication, so prepended directories go on the end with the
+	// first one given last. they are categorised by the same path rules as any other
+	// directory, so one under /home is still counted as user
+	for _, dir := range slices.Backward(prependDataDirs) {
+		dirLevels = append(dirLevels, len(xdgDataDirs))
+		xdgDataDirs = append(xdgDataDirs, dir)
+	}
 
-	applications, err := find(xdgDataDirs, 8)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "find paths: %v\n", err)
+	opts := options{
+		lint:               *lint,
+		verbose:            *verbose,
+		excludeCategories:  excludeCategories,
+		keyword:            *keyword,
+		currentDesktops:    splitDataDirs(os.Getenv("XDG_CURRENT_DESKTOP")),
+		ignoreShowIn:       *ignoreShowIn,
+		noDefaultFilters:   *noDefaultFilters,
+		dropins:            *dropins,
+		locales:            localeCandidates(messagesLocale()),
+		actionExecFallback: *actionExecFallback,
+		validateID:         *validateID,
+		dirLevels:          dirLevels,
+	}
+
+	if *strict {
+		opts.fileErrors = &fileErrors{}
+	}
+
+	if *includeTerminal != "" {
+		for _, name := range strings.Split(*includeTerminal, ",") {
+			if !slices.Contains(categoryNames, name) {
+				fmt.Fprintf(os.Stderr, "unknown category %q, expected one of %s\n", name, strings.Join(categoryNames, ", "))
+				os.Exit(1)
+			}
+			opts.includeTerminalCategories = append(opts.includeTerminalCategories, name)
+		}
+	}
+
+	if *tiebreakCategory != "" {
+		pref, err := parseCategoryPreference(*tiebreakCategory)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "parse tiebreak category: %v\n", err)
+			os.Exit(1)
+		}
+		opts.tiebreak = pref
+	}
+
+	if *resolveIcons || *checkIcons {
+		opts.icons = newIconIndex(iconBaseDirs(xdgDataDirs), *iconTheme, *iconSize)
+		opts.resolveIcons, opts.checkIcons = *resolveIcons, *checkIcons
+	}
+
+	if *cachePath != "" {
+		cache, err := loadParseCache(*cachePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load cache: %v\n", err)
+			os.Exit(1)
+		}
+		opts.cache = cache
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	switch cmd, args := flag.Arg(0), flag.Args(); cmd {
+	case "":
+	case "verify-override":
+		if err := verifyOverride(ctx, xdgDataDirs, opts, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+			os.Exit(1)
+		}
+		return
+	case "launch":
+		if err := launchCommand(ctx, xdgDataDirs, opts, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+			os.Exit(1)
+		}
+		return
+	case "command-histogram":
+		if err := commandHistogram(ctx, xdgDataDirs, opts, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+			os.Exit(1)
+		}
+		return
+	case "completion":
+		if err := completion(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+			os.Exit(1)
+		}
+		return
+	case "mime":
+		if err := mimeQuery(ctx, xdgDataDirs, opts, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		os.Exit(1)
+	}
+
+	if *stream && !*jsonl {
+		fmt.Fprintln(os.Stderr, "-stream needs -jsonl")
+		os.Exit(1)
+	}
+	if *rawStream {
+		opts.noDefaultFilters = true
+	}
+	if *jsonl && *stream || *rawStream {
+		if err := streamJSON(ctx, os.Stdout, xdgDataDirs, opts, *rawStream); err != nil {
+			fmt.Fprintf(os.Stderr, "stream: %v\n", err)
+			os.Exit(1)
+		}
+		if err := opts.fileErrors.err(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if opts.cache != nil {
+			if err := opts.cache.save(*cachePath); err != nil {
+				fmt.Fprintf(os.Stderr, "save cache: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	var scores map[string]float64
+	if *scoresPath != "" {
+		var err error
+		scores, err = readScores(*scoresPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read scores: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// the merged entries are only listed again as actions, so they'd be lost without them
+	if *mergeNames && !*withActions {
+		fmt.Fprintln(os.Stderr, "-merge-duplicate-names-into-actions needs -actions")
 		os.Exit(1)
 	}
 
+	var merged []*application
+	if *mergeFrom != "" {
+		if *mergeDedup != mergeKeepLocal && *mergeDedup != mergeKeepMerged && *mergeDedup != mergeKeepBoth {
+			fmt.Fprintf(os.Stderr, "unknown merge dedup %q\n", *mergeDedup)
+			os.Exit(1)
+		}
+		// both listings' entries for an id have the same id, which outputs keyed by id can't hold
+		if *mergeDedup == mergeKeepBoth && (*sqlitePath != "" || *jsonMapBy == jsonMapByID) {
+			fmt.Fprintf(os.Stderr, "-merge-dedup %s can't be used with -sqlite or -json-map-by %s\n", mergeKeepBoth, jsonMapByID)
+			os.Exit(1)
+		}
+		var err error
+		label := *mergeLabel
+		if label == "" {
+			label = *mergeFrom
+		}
+		merged, err = readListing(*mergeFrom, label)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read merge listing: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var history launchHistory
+	if *historyPath != "" {
+		var err error
+		history, err = readHistory(*historyPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read history: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var baseline map[string]bool
+	if *baselinePath != "" {
+		var err error
+		baseline, err = readIDs(*baselinePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// notInBaseline is how many entries the last listing had that aren't in -baseline
+	var notInBaseline int
+
+	// with -watch the listing is made again on every change, from an index of the entries
+	applicationIndex := newIndex(xdgDataDirs, &opts)
+
+	list := func(w io.Writer) error {
+		if *strict {
+			opts.fileErrors = &fileErrors{}
+		}
+		if *reportFlag {
+			opts.report = newReport(xdgDataDirs)
+		}
+
+		if err := applicationIndex.reload(ctx); err != nil {
+			return fmt.Errorf("find paths: %w", err)
+		}
+		applications := applicationIndex.all()
+		if merged != nil {
+			applications = mergeListings(applications, copyApplications(merged), *mergeDedup)
+		}
+		if err := opts.fileErrors.err(); err != nil {
+			return err
+		}
+		if opts.cache != nil {
+			if err := opts.cache.save(*cachePath); err != nil {
+				return fmt.Errorf("save cache: %w", err)
+			}
+		}
+
+		if *reportFlag {
+			return opts.report.write(w)
+		}
+
+		if *mergeNames {
+			applications = mergeDuplicateNames(applications)
+		}
+
+		switch {
+		case *withActions && *collapseActions:
+			for _, appl := range applications {
+				appl.actionEntriesJSON = appl.actionEntries()
+			}
+		case *withActions:
+			var withActionEntries []*application
+			for _, appl := range applications {
+				withActionEntries = append(withActionEntries, appl)
+				withActionEntries = append(withActionEntries, appl.actionEntries()...)
+			}
+			applications = withActionEntries
+		}
+
+		var notRunnable int
+		if *onlyRunnable {
+			applications = slices.DeleteFunc(applications, func(appl *application) bool {
+				if err := runnable(appl.exec, appl.workingDir); err != nil {
+					if opts.verbose {
+						log.Printf("%q isn't runnable: %v", appl.applicationFile, err)
+					}
+					notRunnable++
+					return true
+				}
+				return false
+			})
+		}
+		if baseline != nil {
+			applications = slices.DeleteFunc(applications, func(appl *application) bool {
+				return baseline[appl.id]
+			})
+			notInBaseline = len(applications)
+		}
+		if *stats {
+			fmt.Fprintf(os.Stderr, "%d entries\n", len(applications))
+			if *onlyRunnable {
+				fmt.Fprintf(os.Stderr, "%d not runnable\n", notRunnable)
+			}
+		}
+
+		for _, appl := range withNested(applications) {
+			appl.score = scores[appl.id]
+		}
+		if history != nil {
+			for _, appl := range withNested(applications) {
+				appl.launches = history.stats(appl.id)
+			}
+		}
+		if *mergeTags {
+			for _, appl := range withNested(applications) {
+				appl.tags = appl.mergedTags()
+			}
+		}
+		if *systemdScope {
+			for _, appl := range withNested(applications) {
+				if appl.command == "" {
+					continue
+				}
+				command, err := systemdScopeCommand(appl.id, appl.command)
+				if err != nil {
+					return fmt.Errorf("systemd scope for %q: %w", appl.applicationFile, err)
+				}
+				appl.command = command
+			}
+		}
+		if *withSlug {
+			assignSlugs(withNested(applications))
+		}
+		if *sortBy == sortScore {
+			slices.SortStableFunc(applications, func(a, b *application) int {
+				return cmp.Compare(b.score, a.score)
+			})
+		}
+
+		if *filterCommand != "" {
+			var err error
+			applications, err = filterCmd(ctx, *filterCommand, applications)
+			if err != nil {
+				return fmt.Errorf("filter: %w", err)
+			}
+		}
+		if *maxNameLength > 0 {
+			for _, appl := range withNested(applications) {
+				appl.name = truncateName(appl.name, *maxNameLength)
+			}
+		}
+		if *sanitize {
+			for _, appl := range withNested(applications) {
+				appl.sanitize()
+			}
+		}
+		if *pathRoot != "" {
+			for _, appl := range withNested(applications) {
+				appl.applicationFile = relativePath(*pathRoot, appl.applicationFile)
+				appl.dir = relativePath(*pathRoot, appl.dir)
+			}
+		}
+
+		switch {
+		case *sqlitePath != "":
+			if err := writeSQLite(*sqlitePath, applications); err != nil {
+				return fmt.Errorf("write sqlite: %w", err)
+			}
+		case *hash:
+			h := sha256.New()
+			writeText(h, applications, defaultTextFormat)
+			fmt.Fprintln(w, hex.EncodeToString(h.Sum(nil)))
+		case *namesOnly:
+			for _, appl := range applications {
+				fmt.Fprintln(w, appl.name)
+			}
+		case *rofi:
+			writeRofi(w, applications)
+		case *fuzzel:
+			writeFuzzel(w, applications)
+		case *wofi:
+			writeWofi(w, applications)
+		case *shellAliases:
+			writeShellAliases(w, applications)
+		case format != nil:
+			if err := writeFormat(w, applications, format, recordSep); err != nil {
+				return fmt.Errorf("write: %w", err)
+			}
+		case *gnomeSearch:
+			if err := writeGNOMESearch(w, applications); err != nil {
+				return fmt.Errorf("write: %w", err)
+			}
+		case *jsonMapBy != "":
+			if err := writeJSONMap(w, applications, *jsonMapBy); err != nil {
+				return fmt.Errorf("write: %w", err)
+			}
+		case *jsonl:
+			if err := writeJSONL(w, applications); err != nil {
+				return fmt.Errorf("write: %w", err)
+			}
+		default:
+			writeText(w, applications, textFormat{
+				fieldSep:    fieldSep,
+				recordSep:   recordSep,
+				shadowCount: *showShadowCount,
+				path:        *showPath,
+				dir:         *showDir,
+			})
+		}
+		return nil
+	}
+
+	if *watchDirs {
+		if err := watch(ctx, xdgDataDirs, list, *onChange, *outputPath); err != nil {
+			fmt.Fprintf(os.Stderr, "watch: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *outputPath != "" {
+		if err := writeOutput(*outputPath, list); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	} else if err := list(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if notInBaseline > 0 {
+		fmt.Fprintf(os.Stderr, "%d entries not in baseline\n", notInBaseline)
+		os.Exit(1)
+	}
+}
+
+// writeOutput replaces path atomically with the output of list, so that readers never see a
+// partial listing
+func writeOutput(path string, list func(io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := list(&buf); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	return nil
+}
+
+// textFormat is how the default output is laid out. optional columns come after the command
+type textFormat struct {
+	fieldSep, recordSep string
+
+	shadowCount bool
+	path        bool
+	dir         bool
+}
+
+var defaultTextFormat = textFormat{fieldSep: "\t", recordSep: "\n"}
+
+func writeText(w io.Writer, applications []*application, format textFormat) {
+	for _, appl := range applications {
+		fields := []string{appl.category.String(), appl.id, appl.command}
+		if format.shadowCount {
+			fields = append(fields, strconv.Itoa(appl.shadowedCount))
+		}
+		if format.dir {
+			fields = append(fields, appl.dir)
+		}
+		if format.path {
+			fields = append(fields, appl.applicationFile)
+		}
+		for _, field := range fields {
+			if strings.Contains(field, format.fieldSep) || strings.Contains(field, format.recordSep) {
+				log.Printf("value %q from %q contains a separator", field, appl.applicationFile)
+			}
+		}
+		fmt.Fprint(w, strings.Join(fields, format.fieldSep), format.recordSep)
+	}
+}
+
+// assignSlugs gives each entry a slug from its name, in lower case with spaces as hyphens and
+// anything other than ascii letters, digits, and hyphens dropped, without hyphens at either
+// end. a name that leaves nothing
+// uses the id instead. when slugs collide, the first entry in precedence order keeps it and
+// later ones get -2, -3, and so on
+func assignSlugs(applications []*application) {
+	used := map[string]bool{}
+	for _, appl := range applications {
+		base := slugify(appl.name)
+		if base == "" {
+			base = slugify(appl.id)
+		}
+		slug := base
+		for i := 2; used[slug]; i++ {
+			slug = base + "-" + strconv.Itoa(i)
+		}
+		used[slug] = true
+		appl.slug = slug
+	}
+}
+
+func slugify(s string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		case r == ' ':
+			return '-'
+		}
+		return -1
+	}, s), "-")
+}
+
+// truncateName shortens name to at most max runes, the last of them an ellipsis, so that
+// multibyte characters aren't split
+func truncateName(name string, max int) string {
+	if utf8.RuneCountInString(name) <= max {
+		return name
+	}
+	runes := []rune(name)
+	return string(runes[:max-1]) + "…"
+}
+
+// relativePath returns path relative to root if it's under it, otherwise path as it is
+func relativePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
+
+// writeRofi prints a row per entry using rofi's dmenu extended row format, the display name
+// followed by the id as the info option so that a script can launch the selection
+// https://github.com/davatorium/rofi/blob/next/doc/rofi-script.5.markdown
+func writeRofi(w io.Writer, applications []*application) {
+	for _, appl := range applications {
+		fmt.Fprintf(w, "%s\x00info\x1f%s\n", appl.name, appl.id)
+	}
+}
+
+// writeFuzzel prints a row per entry for fuzzel's dmenu mode, which understands the icon
+// option from rofi's extended row format
+func writeFuzzel(w io.Writer, applications []*application) {
 	for _, appl := range applications {
-		fmt.Fprintf(os.Stdout, "%s\t%s\t%s\n", appl.category, appl.name, appl.command)
+		if appl.icon == "" {
+			fmt.Fprintf(w, "%s\n", appl.name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\x00icon\x1f%s\n", appl.name, appl.icon)
+	}
+}
+
+// writeWofi prints a row per entry for wofi's dmenu mode. wofi loads images from a path rather
+// than by icon name, so rows only get an image when Icon= is absolute
+func writeWofi(w io.Writer, applications []*application) {
+	for _, appl := range applications {
+		if !filepath.IsAbs(appl.icon) {
+			fmt.Fprintf(w, "%s\n", appl.name)
+			continue
+		}
+		fmt.Fprintf(w, "img:%s:text:%s\n", appl.icon, appl.name)
+	}
+}
+
+// writeShellAliases prints an alias for each entry, named after its id and running its Exec
+// without any field codes. ids are made into alias names that are valid shell identifiers by
+// turning every character other than [A-Za-z0-9_] into an underscore, and putting one in
+// front of a leading digit
+func writeShellAliases(w io.Writer, applications []*application) {
+	for _, appl := range applications {
+		name := strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, appl.id)
+		if !isEnvName(name) {
+			name = "_" + name
+		}
+		commands, err := appl.expandExec(nil)
+		if err != nil {
+			log.Printf("skipping alias for %q: %v", appl.applicationFile, err)
+			continue
+		}
+		fmt.Fprintf(w, "alias %s=%s\n", name, shellQuote(shellJoin(commands[0])))
+	}
+}
+
+// shellQuote quotes s for a POSIX shell, leaving it alone when that isn't needed
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellJoin quotes each of args and joins them into a single command line
+func shellJoin(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+var separatorUnescaper = strings.NewReplacer(
+	`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r", `\0`, "\x00",
+)
+
+// unescapeSeparator expands the backslash escapes accepted by -field-sep and -record-sep,
+// anything else is taken literally
+func unescapeSeparator(sep string) string {
+	return separatorUnescaper.Replace(sep)
+}
+
+const (
+	sortDir   = "dir"
+	sortScore = "score"
+)
+
+// readScores reads a file of whitespace separated id and score pairs, one per line. blank
+// lines and lines starting with # are ignored
+func readScores(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open scores file: %w", err)
+	}
+	defer f.Close()
+
+	scores := map[string]float64{}
+
+	reader := bufio.NewScanner(f)
+	for lineNum := 1; reader.Scan(); lineNum++ {
+		line := strings.TrimSpace(reader.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected id and score", lineNum)
+		}
+		score, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parse score: %w", lineNum, err)
+		}
+		scores[fields[0]] = score
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("read scores file: %w", err)
+	}
+	return scores, nil
+}
+
+// readIDs reads a file of ids, one per line, optionally with the .desktop suffix. blank lines
+// and lines starting with # are ignored
+func readIDs(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open ids file: %w", err)
+	}
+	defer f.Close()
+
+	ids := map[string]bool{}
+
+	reader := bufio.NewScanner(f)
+	for reader.Scan() {
+		line := strings.TrimSpace(reader.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids[strings.TrimSuffix(line, desktopSuffix)] = true
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("read ids file: %w", err)
+	}
+	return ids, nil
+}
+
+// splitDataDirs splits a path list like $XDG_DATA_DIRS. empty segments are dropped,
+// otherwise a stray separator would have the current directory scanned
+func splitDataDirs(value string) []string {
+	return slices.DeleteFunc(strings.Split(value, string(os.PathListSeparator)), func(dir string) bool {
+		return dir == ""
+	})
+}
+
+type options struct {
+	lint              bool
+	verbose           bool
+	excludeCategories []string
+	keyword           string
+	currentDesktops   []string
+	ignoreShowIn      bool
+	locales           []string
+	cache             *parseCache
+	fileErrors        *fileErrors
+	report            *report
+	icons             *iconIndex
+	resolveIcons      bool
+	checkIcons        bool
+
+	// actionExecFallback uses the Exec of the first action that has one for entries without
+	// their own, typically DBusActivatable ones
+	actionExecFallback bool
+
+	// validateID reports ids that aren't reverse-DNS names, fatally with -strict
+	validateID bool
+
+	// includeTerminalCategories are the category names that Terminal=true entries are listed
+	// for, see includeTerminal
+	includeTerminalCategories []string
+
+	// dropins merges foo.desktop.d/*.conf over foo.desktop, see readDropins. they're read
+	// again every time rather than cached
+	dropins bool
+
+	// noDefaultFilters keeps entries that the built-in filters would drop, tagged with the
+	// reasons in excluded_by instead
+	noDefaultFilters bool
+
+	// dirLevels are the precedence of each data directory, by index. directories that share a
+	// level go to tiebreak before the later one wins. nil means every directory has its own
+	dirLevels []int
+	tiebreak  categoryPreference
+}
+
+// fileErrors collects problems with individual files for -strict. without it, or when it's
+// nil, they are only logged
+type fileErrors struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (e *fileErrors) report(err error) {
+	if e == nil {
+		log.Print(err)
+		return
 	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.errs = append(e.errs, err)
+}
+
+func (e *fileErrors) err() error {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// workers report in any order
+	slices.SortFunc(e.errs, func(a, b error) int {
+		return cmp.Compare(a.Error(), b.Error())
+	})
+	return errors.Join(e.errs...)
 }
 
+// icon looks up an Icon= value of file in the icon theme, returning the file it was found in
+// with -resolve-icons, or the value as it is
+func (o options) icon(file, icon string) string {
+	path, ok := o.icons.lookup(icon)
+	if !ok && o.checkIcons {
+		log.Printf("lint %q: icon %q not found", file, icon)
+	}
+	if o.resolveIcons {
+		return path
+	}
+	return icon
+}
+
+// keep reports whether the winning entry for an id should be in the results
+func (o options) keep(a *application) bool {
+	return o.skipReason(a) == ""
+}
+
+// reasons an entry is left out of the results
+const (
+	skipNotApplication  = "not_application"
+	skipNoExec          = "no_exec"
+	skipShadowed        = "shadowed"
+	skipNoDisplay       = "no_display"
+	skipHidden          = "hidden"
+	skipTerminal        = "terminal"
+	skipShowIn          = "show_in"
+	skipExcludeCategory = "exclude_category"
+	skipKeyword         = "keyword"
+)
+
+// skipReason returns why the winning entry for an id is filtered out of the results, or ""
+// if it's kept. it's only meaningful for the entry that won de-duplication, so that an
+// override can both hide and un-hide an application
+func (o options) skipReason(a *application) string {
+	if len(a.excludedBy) > 0 && !o.noDefaultFilters {
+		return a.excludedBy[0]
+	}
+	for _, categ := range o.excludeCategories {
+		if slices.ContainsFunc(a.categories, func(c string) bool { return strings.EqualFold(c, categ) }) {
+			return skipExcludeCategory
+		}
+	}
+	if o.keyword != "" {
+		matches := func(v string) bool { return strings.EqualFold(v, o.keyword) }
+		if !slices.ContainsFunc(a.keywords, matches) && !slices.ContainsFunc(a.categories, matches) {
+			return skipKeyword
+		}
+	}
+	return ""
+}
+
+// includeTerminal reports whether Terminal=true entries of a category are listed. every name
+// the category is made of has to be included, so with "system,user" terminal flatpaks, user
+// or system, are still left out
+func (o options) includeTerminal(c category) bool {
+	if o.includeTerminalCategories == nil {
+		return false
+	}
+	for _, name := range strings.Fields(c.String()) {
+		if !slices.Contains(o.includeTerminalCategories, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// stringsFlag collects the values of a flag that may be given more than once
+type stringsFlag []string
+
+func (f *stringsFlag) String() string     { return strings.Join(*f, ", ") }
+func (f *stringsFlag) Set(v string) error { *f = append(*f, v); return nil }
+
 type application struct {
 	dirIndex        int
 	applicationFile string
+	dir             string
 	category        category
+	source          string
+	id              string
 	name            string
+	genericName     string
+	comment         string
+	icon            string
 	command         string
+	exec            string
+	workingDir      string
+	env             []string
+	execFallback    bool
+	actions         []action
+	action          string
+	categories      []string
+	keywords        []string
+	mimeTypes       []string
+	hints           hints
+	score           float64
+	shadowedCount   int
+	tags            []string
+	slug            string
+	launches        *launchStats
+
+	noDisplay bool
+	hidden    bool
+	terminal  bool
+
+	// excludedBy are the reasons the built-in filters leave the entry out, most important
+	// first
+	excludedBy []string
+
+	// actionEntriesJSON are the action entries nested under this one for -collapse-actions
+	actionEntriesJSON []*application
+}
+
+// showIn reports whether an entry should be shown in the current desktops, from
+// $XDG_CURRENT_DESKTOP. they are checked in order, and the first one in OnlyShowIn= or
+// NotShowIn= decides. if none are, the entry is shown unless it has an OnlyShowIn=
+func showIn(onlyShowIn, notShowIn, currentDesktops []string) bool {
+	for _, desktop := range currentDesktops {
+		if slices.Contains(onlyShowIn, desktop) {
+			return true
+		}
+		if slices.Contains(notShowIn, desktop) {
+			return false
+		}
+	}
+	return onlyShowIn == nil
 }
 
-func find(xdgDataDirs []string, numWorkers int) ([]*application, error) {
+// sanitize strips control characters from every field that's printed, so that an entry can't
+// send escape sequences to a terminal or break up the rows of a launcher. that's C0, DEL, and
+// C1, including tabs and newlines, which no field should contain
+func (a *application) sanitize() {
+	strip := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	stripAll := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+		stripped := make([]string, 0, len(values))
+		for _, v := range values {
+			stripped = append(stripped, strip(v))
+		}
+		return stripped
+	}
+
+	for _, s := range []*string{
+		&a.applicationFile, &a.dir, &a.source, &a.id, &a.name, &a.genericName, &a.comment, &a.icon,
+		&a.command, &a.exec, &a.workingDir, &a.action,
+	} {
+		*s = strip(*s)
+	}
+	a.env, a.categories, a.keywords = stripAll(a.env), stripAll(a.categories), stripAll(a.keywords)
+	a.mimeTypes, a.tags = stripAll(a.mimeTypes), stripAll(a.tags)
+
+	actions := make([]action, 0, len(a.actions))
+	for _, act := range a.actions {
+		act.id, act.name, act.icon = strip(act.id), strip(act.name), strip(act.icon)
+		act.exec, act.command, act.categories = strip(act.exec), strip(act.command), stripAll(act.categories)
+		actions = append(actions, act)
+	}
+	a.actions = actions
+}
+
+// withNested returns applications along with the action entries nested under each of them
+// for -collapse-actions, so that changes made to every entry reach those too
+func withNested(applications []*application) []*application {
+	var entries []*application
+	for _, appl := range applications {
+		entries = append(entries, appl)
+		entries = append(entries, appl.actionEntriesJSON...)
+	}
+	return entries
+}
+
+// mergedTags returns the entry's categories, keywords, and the words of its generic name in
+// lower case, in that order and without duplicates
+func (a *application) mergedTags() []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range slices.Concat(a.categories, a.keywords, strings.Fields(a.genericName)) {
+		tag = strings.ToLower(tag)
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (a *application) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.toJSON())
+}
+
+func (a *application) toJSON() applicationJSON {
+	return applicationJSON{
+		ID:            a.id,
+		Action:        a.action,
+		Name:          a.name,
+		Icon:          a.icon,
+		Category:      a.category.String(),
+		Source:        a.source,
+		Command:       a.command,
+		Env:           a.env,
+		ExecFallback:  a.execFallback,
+		Categories:    a.categories,
+		Keywords:      a.keywords,
+		MimeTypes:     a.mimeTypes,
+		Hints:         a.hints,
+		File:          a.applicationFile,
+		DirIndex:      a.dirIndex,
+		Dir:           a.dir,
+		Score:         a.score,
+		ShadowedCount: a.shadowedCount,
+		Tags:          a.tags,
+		Slug:          a.slug,
+		launchStats:   a.launches,
+		Actions:       a.actionEntriesJSON,
+		ExcludedBy:    a.excludedBy,
+	}
+}
+
+type applicationJSON struct {
+	ID            string   `json:"id"`
+	Action        string   `json:"action,omitempty"`
+	Name          string   `json:"name"`
+	Icon          string   `json:"icon"`
+	Category      string   `json:"category"`
+	Source        string   `json:"source,omitempty"`
+	Command       string   `json:"command"`
+	Env           []string `json:"env,omitempty"`
+	ExecFallback  bool     `json:"exec_fallback,omitempty"`
+	Categories    []string `json:"categories"`
+	Keywords      []string `json:"keywords"`
+	MimeTypes     []string `json:"mime_types"`
+	Hints         hints    `json:"hints"`
+	File          string   `json:"file"`
+	DirIndex      int      `json:"dir_index"`
+	Dir           string   `json:"dir"`
+	Score         float64  `json:"score"`
+	ShadowedCount int      `json:"shadowed_count"`
+	Tags          []string `json:"tags,omitempty"`
+	Slug          string   `json:"slug,omitempty"`
+	*launchStats
+	Actions    []*application `json:"actions,omitempty"`
+	ExcludedBy []string       `json:"excluded_by,omitempty"`
+}
+
+func find(ctx context.Context, xdgDataDirs []string, numWorkers int, opts options) ([]*application, error) {
+	results, err := resolve(ctx, xdgDataDirs, numWorkers, opts)
+	if err != nil {
+		return nil, err
+	}
+	return opts.filter(results), nil
+}
+
+// filter removes the resolved entries that aren't listed, in place, counting each in the report
+func (o options) filter(results []*application) []*application {
+	return slices.DeleteFunc(results, func(appl *application) bool {
+		if reason := o.skipReason(appl); reason != "" {
+			o.report.skip(appl.dirIndex, reason)
+			return true
+		}
+		o.report.keep(appl.dirIndex)
+		return false
+	})
+}
+
+// resolve returns the winning entry for every id, before any of them are filtered out
+func resolve(ctx context.Context, xdgDataDirs []string, numWorkers int, opts options) ([]*application, error) {
+	// ids are compared case insensitively. on a case insensitive mount Firefox.desktop and
+	// firefox.desktop can otherwise both end up listed, depending on what the filesystem
+	// reports
+	var winners = map[string]*application{}
+	var shadowed = map[string]int{}
+
+	for appl := range scan(ctx, xdgDataDirs, numWorkers, opts) {
+		key := strings.ToLower(appl.id)
+		prev, ok := winners[key]
+		if !ok {
+			winners[key] = appl
+			continue
+		}
+		winner := prev
+		if opts.outranks(appl, prev) {
+			winner = appl
+		}
+		if opts.verbose && appl.id != prev.id {
+			log.Printf("%q and %q have ids that differ only in case, keeping %q", prev.applicationFile, appl.applicationFile, winner.applicationFile)
+		}
+		winners[key] = winner
+		shadowed[key]++
+		if winner == appl {
+			opts.report.skip(prev.dirIndex, skipShadowed)
+		} else {
+			opts.report.skip(appl.dirIndex, skipShadowed)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var results []*application
+	for key, appl := range winners {
+		appl.shadowedCount = shadowed[key]
+		results = append(results, appl)
+	}
+
+	slices.SortFunc(results, func(a, b *application) int {
+		return cmp.Or(
+			cmp.Compare(a.dirIndex, b.dirIndex),
+			cmp.Compare(a.id, b.id),
+		)
+	})
+
+	return results, nil
+}
+
+// outranks reports whether a should be kept over b when they share an id. the directory with
+// the higher level wins. entries at the same level, like those of one -flatpak-app, go to
+// the -tiebreak-category preference if there is one, then to the later directory, and then
+// to the lowest sorting id, for ids that only differ in case
+func (o options) outranks(a, b *application) bool {
+	return cmp.Or(
+		cmp.Compare(o.dirLevel(a.dirIndex), o.dirLevel(b.dirIndex)),
+		cmp.Compare(o.tiebreak.score(a.category), o.tiebreak.score(b.category)),
+		cmp.Compare(a.dirIndex, b.dirIndex),
+		cmp.Compare(b.id, a.id),
+	) > 0
+}
+
+func (o options) dirLevel(dirIndex int) int {
+	if o.dirLevels == nil {
+		return dirIndex
+	}
+	return o.dirLevels[dirIndex]
+}
+
+// categoryPreference favours entries with a category such as "flatpak", or without it when
+// negated as "!flatpak". the zero value has no preference
+type categoryPreference struct {
+	name   string
+	negate bool
+}
+
+func parseCategoryPreference(value string) (categoryPreference, error) {
+	var pref categoryPreference
+	pref.name, pref.negate = strings.CutPrefix(value, "!")
+	if !slices.Contains(categoryNames, pref.name) {
+		return categoryPreference{}, fmt.Errorf("unknown category %q, expected one of %s", pref.name, strings.Join(categoryNames, ", "))
+	}
+	return pref, nil
+}
+
+func (p categoryPreference) score(c category) int {
+	if p.name == "" {
+		return 0
+	}
+	if slices.Contains(strings.Fields(c.String()), p.name) != p.negate {
+		return 1
+	}
+	return 0
+}
+
+// scan sends applications on the returned channel as they are parsed, in no particular
+// order and without de-duplication. the channel is closed once every directory has been
+// read or ctx is done
+func scan(ctx context.Context, xdgDataDirs []string, numWorkers int, opts options) <-chan *application {
 	type applicationIndexed struct {
-		dirIndex int
-		path     string
+		dirIndex  int
+		dir       string
+		path      string
+		cached    bool
+		keyValues []keyValue
 	}
 
 	applicationPaths := make(chan applicationIndexed)
 	go func() {
+		defer close(applicationPaths)
 		for i, dataDir := range xdgDataDirs {
 			applicationDir := filepath.Join(dataDir, applicationsPath)
+			if files, ok := opts.cache.lookup(applicationDir); ok {
+				for _, file := range files {
+					select {
+					case applicationPaths <- applicationIndexed{
+						dirIndex:  i,
+						dir:       applicationDir,
+						path:      file.path,
+						cached:    true,
+						keyValues: file.keyValues,
+					}:
+					case <-ctx.Done():
+						return
+					}
+				}
+				continue
+			}
 			dirEnt, err := os.ReadDir(applicationDir)
 			if err != nil {
 				continue
@@ -64,14 +1297,17 @@ func find(xdgDataDirs []string, numWorkers int) ([]*application, error) {
 				if ent.IsDir() || !strings.HasSuffix(ent.Name(), desktopSuffix) {
 					continue
 				}
-				applicationPaths <- applicationIndexed{
+				select {
+				case applicationPaths <- applicationIndexed{
 					dirIndex: i,
+					dir:      applicationDir,
 					path:     filepath.Join(applicationDir, ent.Name()),
+				}:
+				case <-ctx.Done():
+					return
 				}
-
 			}
 		}
-		close(applicationPaths)
 	}()
 
 	applications := make(chan *application)
@@ -80,17 +1316,48 @@ func find(xdgDataDirs []string, numWorkers int) ([]*application, error) {
 		for i := 0; i < numWorkers; i++ {
 			wg.Add(1)
 			go func() {
+				defer wg.Done()
 				for applicationFile := range applicationPaths {
-					appl, err := parse(applicationFile.path, applicationFile.dirIndex)
-					if err != nil {
-						log.Printf("error checking file %q: %v", applicationFile, err)
+					keyValues := applicationFile.keyValues
+					if applicationFile.cached {
+						opts.report.file(applicationFile.dirIndex)
+					} else {
+						var err error
+						keyValues, err = readKeyValues(applicationFile.path)
+						opts.report.file(applicationFile.dirIndex)
+						if err != nil {
+							opts.report.fail(applicationFile.dirIndex)
+							opts.cache.fail(applicationFile.dir)
+							opts.fileErrors.report(fmt.Errorf("error checking file %q: %w", applicationFile.path, err))
+							continue
+						}
+						opts.cache.store(applicationFile.dir, applicationFile.path, keyValues)
+					}
+					if opts.dropins {
+						var err error
+						keyValues, err = readDropins(applicationFile.path, keyValues)
+						if err != nil {
+							opts.report.fail(applicationFile.dirIndex)
+							opts.fileErrors.report(fmt.Errorf("error checking file %q: %w", applicationFile.path, err))
+							continue
+						}
+					}
+					appl := parse(applicationFile.path, applicationFile.dirIndex, keyValues, opts)
+					if appl == nil {
 						continue
 					}
-					if appl != nil {
-						applications <- appl
+					if opts.icons != nil {
+						appl.icon = opts.icon(appl.applicationFile, appl.icon)
+						for i := range appl.actions {
+							appl.actions[i].icon = opts.icon(appl.applicationFile, appl.actions[i].icon)
+						}
+					}
+					select {
+					case applications <- appl:
+					case <-ctx.Done():
+						return
 					}
 				}
-				wg.Done()
 			}()
 		}
 		wg.Wait()
@@ -98,26 +1365,95 @@ func find(xdgDataDirs []string, numWorkers int) ([]*application, error) {
 		close(applications)
 	}()
 
-	var results []*application
-	var maxIndexes = map[string]int{}
+	return applications
+}
 
-	for appl := range applications {
-		results = append(results, appl)
-		maxIndexes[appl.name] = max(maxIndexes[appl.name], appl.dirIndex)
+// streamJSON writes applications to w as newline delimited json as soon as they are parsed.
+// a slow writer holds up the workers rather than buffering results. unless raw, entries that
+// would be filtered out of the listing are skipped
+func streamJSON(ctx context.Context, w io.Writer, xdgDataDirs []string, opts options, raw bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	enc := json.NewEncoder(w)
+	for appl := range scan(ctx, xdgDataDirs, 8, opts) {
+		if !raw && !opts.keep(appl) {
+			continue
+		}
+		if err := enc.Encode(appl); err != nil {
+			return fmt.Errorf("encode %q: %w", appl.applicationFile, err)
+		}
 	}
+	return ctx.Err()
+}
 
-	results = slices.DeleteFunc(results, func(appl *application) bool {
-		return appl.dirIndex < maxIndexes[appl.name]
-	})
+func writeJSONL(w io.Writer, applications []*application) error {
+	enc := json.NewEncoder(w)
+	for _, appl := range applications {
+		if err := enc.Encode(appl); err != nil {
+			return fmt.Errorf("encode %q: %w", appl.applicationFile, err)
+		}
+	}
+	return nil
+}
 
-	slices.SortFunc(results, func(a, b *application) int {
-		return cmp.Or(
-			cmp.Compare(a.dirIndex, b.dirIndex),
-			cmp.Compare(a.name, b.name),
-		)
-	})
+// gnomeSearchMeta is a result meta from a GNOME Shell search provider's GetResultMetas. there
+// the icon is usually given as "icon", a serialized GIcon, which has no json form. the shell
+// also takes "gicon", the icon as a string for g_icon_new_for_string, which is what's used
+// https://developer.gnome.org/documentation/tutorials/search-provider.html
+type gnomeSearchMeta struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	GIcon       string `json:"gicon,omitempty"`
+}
 
-	return results, nil
+// writeGNOMESearch writes applications as a json array of search result metas, with Comment=
+// as the description
+func writeGNOMESearch(w io.Writer, applications []*application) error {
+	metas := make([]gnomeSearchMeta, 0, len(applications))
+	for _, appl := range applications {
+		metas = append(metas, gnomeSearchMeta{
+			ID:          appl.id,
+			Name:        appl.name,
+			Description: appl.comment,
+			GIcon:       appl.icon,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(metas); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	return nil
+}
+
+const (
+	jsonMapByID   = "id"
+	jsonMapByName = "name"
+)
+
+// writeJSONMap writes applications as a single json object keyed by id, or by name. names
+// aren't unique, so keyed by name each value is an array of the entries with that name, in
+// listing order
+func writeJSONMap(w io.Writer, applications []*application, by string) error {
+	var v any
+	switch by {
+	case jsonMapByID:
+		byID := map[string]*application{}
+		for _, appl := range applications {
+			byID[appl.id] = appl
+		}
+		v = byID
+	case jsonMapByName:
+		byName := map[string][]*application{}
+		for _, appl := range applications {
+			byName[appl.name] = append(byName[appl.name], appl)
+		}
+		v = byName
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	return nil
 }
 
 // we don't care about passing arguments
@@ -131,40 +1467,160 @@ var commandArgReplacer = strings.NewReplacer(
 	"\t", " ",
 )
 
-func parse(applicationFile string, dirIndex int) (*application, error) {
+func readKeyValues(applicationFile string) ([]keyValue, error) {
 	f, err := os.Open(applicationFile)
 	if err != nil {
 		return nil, fmt.Errorf("open application file: %w", err)
 	}
 	defer f.Close()
 
+	var keyValues []keyValue
+	reader := newEntryReader(f)
+	for reader.Next() {
+		keyValues = append(keyValues, reader.KeyValue())
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("read application file: %w", err)
+	}
+	return keyValues, nil
+}
+
+func parse(applicationFile string, dirIndex int, keyValues []keyValue, opts options) *application {
 	var hasApplication bool
-	var command string
+	var name, genericName, comment, icon, command string
+	var categories []string
+	var hints hints
+	var noDisplay, hidden, terminal bool
+	var actionIDs []string
+	var workingDir string
+	var mimeTypes []string
+	var keywords []string
+	var onlyShowIn, notShowIn []string
+	var actionGroups = map[string]*action{}
+	var translations = map[localizedKey]string{}
 
-	reader := bufio.NewScanner(f)
-sc:
-	for reader.Scan() {
-		switch line := reader.Text(); {
-		case strings.HasPrefix(line, "NoDisplay=true"):
-			return nil, nil
-		case strings.HasPrefix(line, "Terminal=true"):
-			return nil, nil
-		case strings.HasPrefix(line, "Type=Application"):
-			hasApplication = true
-		case strings.HasPrefix(line, "Exec="):
-			_, command, _ = strings.Cut(line, "=")
-		case strings.TrimSpace(line) == "":
-			break sc // only read first block
+	for _, kv := range keyValues {
+		if kv.spaced && opts.lint {
+			key := kv.key
+			if kv.locale != "" {
+				key += "[" + kv.locale + "]"
+			}
+			log.Printf("lint %q: line %d: whitespace around = in %q", applicationFile, kv.line, key)
+		}
+		if kv.locale != "" {
+			translations[localizedKey{kv.group, kv.key, kv.locale}] = kv.value
+			continue
+		}
+		if actionID, ok := strings.CutPrefix(kv.group, desktopActionGroupPrefix); ok {
+			act, ok := actionGroups[actionID]
+			if !ok {
+				act = &action{id: actionID}
+				actionGroups[actionID] = act
+			}
+			switch kv.key {
+			case "Name":
+				act.name = kv.value
+			case "Icon":
+				act.icon = kv.value
+			case "Categories":
+				act.categories = splitList(kv.value)
+			case "Exec":
+				act.exec = kv.value
+				act.command = commandArgReplacer.Replace(kv.value)
+			}
+			continue
+		}
+		if kv.group != desktopEntryGroup {
+			continue
+		}
+		switch kv.key {
+		case "NoDisplay":
+			noDisplay = kv.value == "true"
+		case "Hidden":
+			hidden = kv.value == "true"
+		case "Terminal":
+			terminal = kv.value == "true"
+		case "Type":
+			switch kv.value {
+			case "Application":
+				hasApplication = true
+			case "Link", "Directory":
+			default:
+				if opts.lint {
+					log.Printf("lint %q: unrecognised type %q", applicationFile, kv.value)
+				}
+			}
+		case "Name":
+			name = kv.value
+		case "GenericName":
+			genericName = kv.value
+		case "Comment":
+			comment = kv.value
+		case "Icon":
+			icon = kv.value
+		case "Exec":
+			command = kv.value
+		case "X-GNOME-UsesNotifications":
+			hints.UsesNotifications = kv.value == "true"
+		case "SingleMainWindow":
+			hints.SingleMainWindow = kv.value == "true"
+		case "DBusActivatable":
+			hints.DBusActivatable = kv.value == "true"
+		case "PrefersNonDefaultGPU":
+			hints.PrefersNonDefaultGPU = kv.value == "true"
+		case "Categories":
+			categories = splitList(kv.value)
+		case "Actions":
+			actionIDs = splitList(kv.value)
+		case "Path":
+			workingDir = kv.value
+		case "OnlyShowIn":
+			onlyShowIn = splitList(kv.value)
+		case "NotShowIn":
+			notShowIn = splitList(kv.value)
+		case "Keywords":
+			keywords = splitList(kv.value)
+		case "MimeType":
+			mimeTypes = splitList(kv.value)
 		}
 	}
 
-	if !hasApplication || command == "" {
-		return nil, nil
+	if value, ok := localized(translations, opts.locales, desktopEntryGroup, "Name"); ok {
+		name = value
+	}
+	if value, ok := localized(translations, opts.locales, desktopEntryGroup, "Comment"); ok {
+		comment = value
+	}
+	if value, ok := localized(translations, opts.locales, desktopEntryGroup, "GenericName"); ok {
+		genericName = value
+	}
+	if value, ok := localized(translations, opts.locales, desktopEntryGroup, "Keywords"); ok {
+		keywords = splitList(value)
 	}
 
-	command = commandArgReplacer.Replace(command)
-	name := filepath.Base(applicationFile)
-	name = strings.TrimSuffix(name, desktopSuffix)
+	// only actions listed in Actions= count, in the order they are listed
+	var actions []action
+	for _, actionID := range actionIDs {
+		if act, ok := actionGroups[actionID]; ok {
+			if value, ok := localized(translations, opts.locales, desktopActionGroupPrefix+actionID, "Name"); ok {
+				act.name = value
+			}
+			actions = append(actions, *act)
+		}
+	}
+
+	var execFallback bool
+	if command == "" && opts.actionExecFallback {
+		for _, act := range actions {
+			if act.command != "" {
+				command, execFallback = act.exec, true
+				if opts.verbose {
+					log.Printf("%q has no Exec, using the one from action %q", applicationFile, act.id)
+				}
+				break
+			}
+		}
+	}
 
 	var categ category
 	if strings.HasPrefix(applicationFile, "/home") {
@@ -174,13 +1630,188 @@ sc:
 		categ |= categoryFlatpak
 	}
 
+	// a hidden entry is considered deleted and needs no Exec, but it still has to take part
+	// in de-duplication so that it hides the entries it overrides. other entries that can't
+	// be run are only kept with -no-default-filters. a DBusActivatable entry is activated over
+	// the session bus, so it can be run without an Exec
+	var excludedBy []string
+	if hidden {
+		excludedBy = append(excludedBy, skipHidden)
+	}
+	if !hasApplication {
+		excludedBy = append(excludedBy, skipNotApplication)
+	}
+	if command == "" && !hints.DBusActivatable {
+		excludedBy = append(excludedBy, skipNoExec)
+	}
+	if !hidden && len(excludedBy) > 0 && !opts.noDefaultFilters {
+		opts.report.skip(dirIndex, excludedBy[0])
+		return nil
+	}
+	if noDisplay {
+		excludedBy = append(excludedBy, skipNoDisplay)
+	}
+	if terminal && !opts.includeTerminal(categ) {
+		excludedBy = append(excludedBy, skipTerminal)
+	}
+	if !opts.ignoreShowIn && !showIn(onlyShowIn, notShowIn, opts.currentDesktops) {
+		excludedBy = append(excludedBy, skipShowIn)
+	}
+
+	execValue := command
+	var env []string
+	if args, err := splitExec(execValue); err == nil {
+		env, _ = splitEnvAssignments(args)
+	}
+	command = commandArgReplacer.Replace(command)
+	id := filepath.Base(applicationFile)
+	id = strings.TrimSuffix(id, desktopSuffix)
+	if name == "" {
+		name = id
+	}
+	if opts.validateID {
+		if err := validateDesktopFileID(id); err != nil {
+			opts.fileErrors.report(fmt.Errorf("lint %q: %w", applicationFile, err))
+		}
+	}
+
 	return &application{
 		dirIndex:        dirIndex,
 		applicationFile: applicationFile,
+		dir:             filepath.Dir(applicationFile),
 		category:        categ,
+		id:              id,
 		name:            name,
+		genericName:     genericName,
+		comment:         comment,
+		icon:            icon,
 		command:         command,
-	}, nil
+		exec:            execValue,
+		workingDir:      workingDir,
+		env:             env,
+		execFallback:    execFallback,
+		actions:         actions,
+		categories:      categories,
+		keywords:        keywords,
+		mimeTypes:       mimeTypes,
+		hints:           hints,
+		noDisplay:       noDisplay,
+		hidden:          hidden,
+		terminal:        terminal,
+		excludedBy:      excludedBy,
+	}
+}
+
+// validateDesktopFileID checks that id is a reverse-DNS name such as org.example.App, which
+// follows the rules for D-Bus well-known names. there must be at least two elements separated
+// by dots, each element is made of [A-Za-z0-9_-] and can't start with a digit, and the whole
+// id can be at most 255 characters
+// https://specifications.freedesktop.org/desktop-entry-spec/latest/file-naming.html
+func validateDesktopFileID(id string) error {
+	if len(id) > 255 {
+		return fmt.Errorf("id %q is longer than 255 characters", id)
+	}
+	elements := strings.Split(id, ".")
+	if len(elements) < 2 {
+		return fmt.Errorf("id %q is not a reverse-DNS name", id)
+	}
+	for _, elem := range elements {
+		if elem == "" {
+			return fmt.Errorf("id %q has an empty element", id)
+		}
+		if elem[0] >= '0' && elem[0] <= '9' {
+			return fmt.Errorf("id %q has element %q starting with a digit", id, elem)
+		}
+		for _, r := range elem {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				return fmt.Errorf("id %q has element %q with invalid character %q", id, elem, r)
+			}
+		}
+	}
+	return nil
+}
+
+// action is an entry from a [Desktop Action <id>] group
+type action struct {
+	id         string
+	name       string
+	icon       string
+	categories []string
+	exec       string
+	command    string
+}
+
+// actionEntries returns a's actions as entries of their own, with ids like "firefox:new-window"
+// and names like "Firefox - New Window". other than the spec, which doesn't give actions an
+// Icon or Categories at all, an action without its own inherits them from the application so
+// that it renders like it in a launcher. actions without an Exec are skipped
+func (a *application) actionEntries() []*application {
+	var entries []*application
+	for _, act := range a.actions {
+		if act.exec == "" {
+			continue
+		}
+		entry := *a
+		entry.id = a.id + ":" + act.id
+		entry.name = a.name + " - " + act.name
+		entry.action = act.id
+		entry.exec = act.exec
+		entry.command = act.command
+		entry.execFallback = false
+		entry.actions = nil
+		entry.mimeTypes = nil
+		if act.icon != "" {
+			entry.icon = act.icon
+		}
+		if act.categories != nil {
+			entry.categories = act.categories
+		}
+		entries = append(entries, &entry)
+	}
+	return entries
+}
+
+// mergeDuplicateNames keeps the first of the applications that share a name, adding the others
+// to the end of its actions. an action made from an entry has the entry's id as both its id
+// and its name, so that it can be told apart, and its Exec, Icon, and Categories
+func mergeDuplicateNames(applications []*application) []*application {
+	byName := map[string]*application{}
+	return slices.DeleteFunc(applications, func(appl *application) bool {
+		first, ok := byName[appl.name]
+		if !ok {
+			byName[appl.name] = appl
+			return false
+		}
+		first.actions = append(first.actions, action{
+			id:         appl.id,
+			name:       appl.id,
+			icon:       appl.icon,
+			categories: appl.categories,
+			exec:       appl.exec,
+			command:    appl.command,
+		})
+		return true
+	})
+}
+
+// hints are well known boolean keys that launchers commonly surface as capabilities
+type hints struct {
+	UsesNotifications    bool `json:"uses_notifications"`
+	SingleMainWindow     bool `json:"single_main_window"`
+	DBusActivatable      bool `json:"dbus_activatable"`
+	PrefersNonDefaultGPU bool `json:"prefers_non_default_gpu"`
+}
+
+// splitList splits a value of type string(s), which are separated and optionally terminated
+// by semicolons
+func splitList(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ";") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
 }
 
 type category uint8
@@ -202,3 +1833,6 @@ const (
 	categoryUser category = 1 << iota
 	categoryFlatpak
 )
+
+// categoryNames are the words a category can be printed as
+var categoryNames = []string{"user", "system", "flatpak"}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// findTestdata lists the entries of data directories under testdata, in listing order
+func findTestdata(t *testing.T, opts options, dataDirs ...string) []*application {
+	t.Helper()
+	var xdgDataDirs []string
+	for _, dir := range dataDirs {
+		xdgDataDirs = append(xdgDataDirs, filepath.Join("testdata", dir))
+	}
+	applications, err := find(context.Background(), xdgDataDirs, 2, opts)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	return applications
+}
+
+func byID(applications []*application) map[string]*application {
+	ids := map[string]*application{}
+	for _, appl := range applications {
+		ids[appl.id] = appl
+	}
+	return ids
+}
+
+func TestSplitDataDirs(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{":", nil},
+		{"/usr/share", []string{"/usr/share"}},
+		{"/usr/share:/usr/local/share", []string{"/usr/share", "/usr/local/share"}},
+		{"/usr/share::/usr/local/share", []string{"/usr/share", "/usr/local/share"}},
+		{":/usr/share:", []string{"/usr/share"}},
+	}
+	for _, tt := range tests {
+		if got := splitDataDirs(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("splitDataDirs(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateName(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		want string
+	}{
+		{"Firefox", 10, "Firefox"},
+		{"Firefox", 7, "Firefox"},
+		{"Firefox", 6, "Firef…"},
+		{"日本語入力", 5, "日本語入力"},
+		{"日本語入力", 4, "日本語…"},
+		{"日本語入力", 2, "日…"},
+		{"日本語入力", 1, "…"},
+		{"é", 1, "é"},
+		{"éa", 1, "…"},
+	}
+	for _, tt := range tests {
+		if got := truncateName(tt.name, tt.max); got != tt.want {
+			t.Errorf("truncateName(%q, %d) = %q, want %q", tt.name, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestVisibilityOfWinner(t *testing.T) {
+	applications := byID(findTestdata(t, options{}, "visibility/system", "visibility/user"))
+	tests := []struct {
+		id     string
+		listed bool
+	}{
+		// a user override with NoDisplay=false or Hidden=false brings back a system entry
+		{"reenabled", true},
+		{"unhidden", true},
+		// and one with Hidden=true or NoDisplay=true removes it
+		{"hidden", false},
+		{"nodisplay", false},
+		// without an override the system entry's own NoDisplay=true applies
+		{"untouched", false},
+	}
+	for _, tt := range tests {
+		if _, ok := applications[tt.id]; ok != tt.listed {
+			t.Errorf("%q listed is %t, want %t", tt.id, ok, tt.listed)
+		}
+	}
+	if len(applications) != 2 {
+		t.Errorf("got %d entries, want 2", len(applications))
+	}
+}
+
+// writeDataDirs makes dirs data directories of n entries each, the same ids in each so that
+// later ones shadow earlier ones
+func writeDataDirs(tb testing.TB, dirs, n int) []string {
+	tb.Helper()
+	var xdgDataDirs []string
+	for i := range dirs {
+		dataDir := filepath.Join(tb.TempDir(), strconv.Itoa(i))
+		applicationDir := filepath.Join(dataDir, applicationsPath)
+		if err := os.MkdirAll(applicationDir, 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		for j := range n {
+			entry := fmt.Sprintf("[Desktop Entry]\nType=Application\nName=App %d\nName[de]=Programm %d\nComment=An app\nExec=app%d %%U\nIcon=app%d\nCategories=Utility;Development;\nKeywords=one;two;\nMimeType=text/plain;\n", j, j, j, j)
+			if err := os.WriteFile(filepath.Join(applicationDir, fmt.Sprintf("org.example.App%d.desktop", j)), []byte(entry), 0o644); err != nil {
+				tb.Fatal(err)
+			}
+		}
+		xdgDataDirs = append(xdgDataDirs, dataDir)
+	}
+	return xdgDataDirs
+}
+
+// BenchmarkScan resolves three directories of 2000 entries each with the worker pool. it
+// reads from the page cache, so it measures parsing and the pool more than the disk
+func BenchmarkScan(b *testing.B) {
+	xdgDataDirs := writeDataDirs(b, 3, 2000)
+	opts := options{locales: []string{"de"}}
+	b.ResetTimer()
+	for range b.N {
+		applications, err := resolve(context.Background(), xdgDataDirs, 8, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(applications) != 2000 {
+			b.Fatalf("got %d entries, want 2000", len(applications))
+		}
+	}
+}
+
+// BenchmarkParse parses one large entry, with many translations and actions
+func BenchmarkParse(b *testing.B) {
+	var entry strings.Builder
+	entry.WriteString("[Desktop Entry]\nType=Application\nName=Large\nExec=large %U\nIcon=large\nCategories=Utility;Development;\nMimeType=text/plain;image/png;\n")
+	var actionIDs []string
+	for i := range 50 {
+		actionIDs = append(actionIDs, fmt.Sprintf("action%d", i))
+	}
+	fmt.Fprintf(&entry, "Actions=%s;\n", strings.Join(actionIDs, ";"))
+	for i := range 200 {
+		fmt.Fprintf(&entry, "Name[l%d]=Large %d\nComment[l%d]=A large entry %d\nKeywords[l%d]=a;b;c;\n", i, i, i, i, i)
+	}
+	for _, id := range actionIDs {
+		fmt.Fprintf(&entry, "\n[Desktop Action %s]\nName=%s\nName[de]=%s de\nExec=large --%s\n", id, id, id, id)
+	}
+
+	var keyValues []keyValue
+	reader := newEntryReader(strings.NewReader(entry.String()))
+	for reader.Next() {
+		keyValues = append(keyValues, reader.KeyValue())
+	}
+	if err := reader.Err(); err != nil {
+		b.Fatal(err)
+	}
+
+	opts := options{locales: localeCandidates("de_DE.UTF-8")}
+	b.ResetTimer()
+	for range b.N {
+		if appl := parse("/usr/share/applications/large.desktop", 0, keyValues, opts); appl == nil || len(appl.actions) != 50 {
+			b.Fatal("large entry wasn't parsed with its actions")
+		}
+	}
+}